@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	configtemplates "tcsss/internal/config"
+)
+
+// modeSwitchConfirmations is how many consecutive checks must agree on a new mode before
+// templates are switched, so a short burst of connections does not flip the host role.
+const modeSwitchConfirmations = 3
+
+// confirmedModeEnv hands the confirmed mode to the re-executed process, which starts from it
+// rather than from a single unconfirmed detection.
+const confirmedModeEnv = "TCSSS_CONFIRMED_MODE"
+
+// modeWatcher periodically re-evaluates the host role in auto mode and cancels the daemon
+// once it has changed; main then re-executes itself to apply the new templates from scratch.
+type modeWatcher struct {
+	logger      *slog.Logger
+	templateDir string
+	current     configtemplates.TrafficMode
+	interval    time.Duration
+	cancel      context.CancelFunc
+	// confirmed is the mode to switch to; it is set before switched.
+	confirmed configtemplates.TrafficMode
+	switched  atomic.Bool
+}
+
+func (w *modeWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	var candidate configtemplates.TrafficMode
+	confirmations := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		decision, err := configtemplates.DetectTrafficMode(w.templateDir)
+		if err != nil {
+			w.logger.Warn("traffic mode re-evaluation failed", slog.String("error", err.Error()))
+			continue
+		}
+		if decision.Mode == w.current {
+			candidate, confirmations = "", 0
+			continue
+		}
+		if decision.Mode != candidate {
+			candidate, confirmations = decision.Mode, 0
+		}
+		confirmations++
+		if confirmations < modeSwitchConfirmations {
+			continue
+		}
+
+		w.logger.Info("host role changed, switching traffic mode",
+			slog.String("previous", string(w.current)),
+			slog.String("mode", string(decision.Mode)),
+			slog.Any("reasons", decision.Reasons))
+		w.confirmed = decision.Mode
+		w.switched.Store(true)
+		w.cancel()
+		return
+	}
+}
+
+// logModeDecision reports the detected mode together with the evidence that selected it.
+func logModeDecision(logger *slog.Logger, decision configtemplates.ModeDecision) {
+	logger.Info("traffic mode detected from host behaviour",
+		slog.String("mode", string(decision.Mode)),
+		slog.Any("reasons", decision.Reasons),
+		slog.Int("listening", decision.Sockets.Listening),
+		slog.Int("accept_queue", decision.Sockets.AcceptQueue),
+		slog.Int("inbound", decision.Sockets.Inbound),
+		slog.Int("outbound", decision.Sockets.Outbound),
+		slog.Bool("forwarding", decision.Forwarding),
+		slog.String("nat", decision.NAT),
+		slog.Any("ignored_container_links", decision.IgnoredLinks))
+}
+
+// takeConfirmedMode returns the mode handed over by a previous re-exec, or "" when there is
+// none, and clears it so it is not passed on again.
+func takeConfirmedMode() configtemplates.TrafficMode {
+	value := os.Getenv(confirmedModeEnv)
+	_ = os.Unsetenv(confirmedModeEnv)
+	switch mode := configtemplates.TrafficMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case configtemplates.TrafficModeClient, configtemplates.TrafficModeServer, configtemplates.TrafficModeAggregate:
+		return mode
+	}
+	return ""
+}
+
+// reexec replaces the running process with a fresh copy using the same arguments, handing it
+// the confirmed mode.
+func reexec(mode configtemplates.TrafficMode) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(exe, os.Args, append(os.Environ(), confirmedModeEnv+"="+string(mode)))
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
@@ -13,6 +14,7 @@ import (
 	"syscall"
 	"time"
 
+	"tcsss/internal/alert"
 	"tcsss/internal/app"
 	configtemplates "tcsss/internal/config"
 	"tcsss/internal/detector"
@@ -24,11 +26,26 @@ import (
 func main() {
 	var confDirFlag string
 	var modeFlag string
+	var irqAffinityFlag bool
+	var modeIntervalFlag time.Duration
+	var sysctlPathFlag string
 
 	flag.StringVar(&confDirFlag, "conf", "", "configuration directory path (default: /etc/tcsss)")
-	flag.StringVar(&modeFlag, "mode", "", "traffic mode: client, server, or aggregate")
+	flag.StringVar(&modeFlag, "mode", "", "traffic mode: client, server, aggregate, or auto")
+	flag.DurationVar(&modeIntervalFlag, "mode-interval", 0, "with --mode auto, re-evaluate the host role at this interval and switch templates when it changes (0 disables)")
+	flag.StringVar(&sysctlPathFlag, "sysctl-path", syslimit.DefaultSysctlPath, "sysctl.d drop-in written by tcsss")
+	flag.BoolVar(&irqAffinityFlag, "irq-affinity", false, "spread physical NIC IRQs across online CPUs (disable irqbalance first)")
 	flag.Parse()
 
+	if flag.Arg(0) == "status" {
+		statusCommand()
+		return
+	}
+	if flag.Arg(0) == "sysctl" {
+		sysctlCommand(flag.Args()[1:])
+		return
+	}
+
 	legacyModeArg := ""
 	if flag.NArg() > 0 {
 		legacyModeArg = flag.Arg(0)
@@ -49,6 +66,22 @@ func main() {
 		logger.Warn("legacy mode argument detected; use --mode flag instead", slog.String("argument", legacyModeArg))
 	}
 
+	autoMode := strings.EqualFold(mode, configtemplates.AutoTrafficMode)
+	confirmed := takeConfirmedMode()
+	if autoMode && confirmed != "" {
+		logger.Info("resuming confirmed traffic mode after switch", slog.String("mode", string(confirmed)))
+		mode = string(confirmed)
+	} else if autoMode {
+		decision, err := configtemplates.DetectTrafficMode(templateDir)
+		if err != nil {
+			logger.Warn("traffic mode detection failed, selecting by template files", slog.String("error", err.Error()))
+			mode = ""
+		} else {
+			logModeDecision(logger, decision)
+			mode = string(decision.Mode)
+		}
+	}
+
 	ctx, cancel := signalContext()
 	defer cancel()
 
@@ -63,36 +96,92 @@ func main() {
 	}
 
 	initConfig, err := configtemplates.LoadTrafficInitConfig(templateDir, mode)
+	if errors.Is(err, configtemplates.ErrInvalidTemplate) {
+		// Falling back would run the default mode's windows and sysctl set on this host.
+		logger.Error("traffic template invalid", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
 	if err != nil {
 		logger.Warn("falling back to default traffic template", slog.String("error", err.Error()), slog.String("fallback_mode", string(initConfig.Mode)))
 	}
-	logger.Info("traffic template applied", slog.String("mode", string(initConfig.Mode)))
+	logger.Info("traffic template applied",
+		slog.String("mode", string(initConfig.Mode)),
+		slog.String("nic_profile", initConfig.NICProfile))
+
+	schedule, err := traffic.LoadSchedule(templateDir)
+	if err != nil {
+		logger.Warn("shaping schedule disabled", slog.String("error", err.Error()))
+	} else if len(schedule.Entries) > 0 {
+		logger.Info("shaping schedule loaded", slog.Int("entries", len(schedule.Entries)))
+	}
+
+	routePolicy, err := route.LoadPolicy(templateDir)
+	if err != nil {
+		logger.Warn("route policy ignored", slog.String("error", err.Error()))
+	}
 
 	trafficSettings := traffic.Settings{
 		Routes: route.WindowConfig{
 			InitCwndBytes:       initConfig.InitCwndBytes,
 			InitRwndBytes:       initConfig.InitRwndBytes,
 			LoopbackWindowBytes: initConfig.InitLoopbackWindowBytes,
+			Policy:              routePolicy,
 		},
+		Profiles: traffic.ProfileSettings{
+			NICProfile: initConfig.NICProfile,
+		},
+		Steering: traffic.SteeringSettings{
+			IRQAffinity: irqAffinityFlag,
+		},
+		Schedule: schedule,
 	}
 
 	sysctlApplier := syslimit.NewSysctlConfApplier(logger, templateDir, initConfig.Mode)
+	sysctlApplier.SetSysctlPath(sysctlPathFlag)
 
-	limitsApplier := syslimit.NewLimitsConfApplier(logger, templateDir)
+	limitsApplier := syslimit.NewLimitsConfApplier(logger, templateDir, initConfig.Mode)
 
-	rlimitApplier := syslimit.NewRlimitApplier(logger, templateDir)
+	rlimitApplier := syslimit.NewRlimitApplier(logger, templateDir, initConfig.Mode)
 
 	trafficShaper := traffic.NewShaper(logger, trafficSettings)
 
-	daemon := app.NewDaemon(app.Dependencies{
+	deps := app.Dependencies{
 		SysctlApplier:  sysctlApplier,
 		LimitsApplier:  limitsApplier,
 		RlimitApplier:  rlimitApplier,
 		TrafficManager: trafficShaper,
 		Logger:         logger,
-	})
+	}
+
+	alertConfig, err := alert.LoadConfig(templateDir)
+	if err != nil {
+		logger.Warn("alerting disabled", slog.String("error", err.Error()))
+	} else if alertConfig.Enabled() {
+		deps.AlertMonitor = alert.NewMonitor(logger, alertConfig, trafficShaper)
+	}
+
+	daemon := app.NewDaemon(deps)
 
-	if err := daemon.Run(ctx); err != nil {
+	var watcher *modeWatcher
+	if autoMode && modeIntervalFlag > 0 {
+		watcher = &modeWatcher{
+			logger:      logger,
+			templateDir: templateDir,
+			current:     initConfig.Mode,
+			interval:    modeIntervalFlag,
+			cancel:      cancel,
+		}
+		go watcher.Run(ctx)
+	}
+
+	err = daemon.Run(ctx)
+	if watcher != nil && watcher.switched.Load() {
+		if err := reexec(watcher.confirmed); err != nil {
+			logger.Error("failed to restart with new traffic mode", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+	}
+	if err != nil {
 		logger.Error("daemon terminated", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"tcsss/internal/route"
+	"tcsss/internal/traffic"
+)
+
+// runStatus prints the status file published by the running daemon.
+func runStatus(out io.Writer, path string) error {
+	status, err := traffic.ReadStatus(path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "updated: %s (%s ago)\n", status.UpdatedAt.Local().Format(time.RFC3339),
+		time.Since(status.UpdatedAt).Round(time.Second))
+	fmt.Fprintf(out, "netlink resubscriptions: %d\n\n", status.Resubscriptions)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INTERFACE\tCLASS\tSTATE\tFAILURES\tRETRY\tSCHEDULE\tNEXT\tLAST ERROR")
+	for _, iface := range status.Interfaces {
+		retry := "-"
+		switch {
+		case !iface.HoldUntil.IsZero():
+			retry = time.Until(iface.HoldUntil).Round(time.Second).String()
+		case !iface.NextAttempt.IsZero():
+			retry = time.Until(iface.NextAttempt).Round(time.Second).String()
+		}
+		class := iface.Class
+		if class == "" {
+			class = "-"
+		}
+		schedule := iface.Schedule
+		if schedule == "" {
+			schedule = "-"
+		}
+		next := "-"
+		if iface.NextSchedule != "" {
+			next = iface.NextSchedule + " at " + iface.NextScheduleAt.Local().Format("Mon 15:04")
+		}
+		lastError := iface.LastError
+		if lastError == "" {
+			lastError = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n", iface.Name, class, iface.State, iface.Failures, retry, schedule, next, lastError)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(status.Uplinks) == 0 {
+		return nil
+	}
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "UPLINK\tFAMILIES\tROUTES\tTUNED\tUNCHANGED\tSKIPPED\tFAILED\tMTU\tCONGCTL\tINITCWND\tINITRWND")
+	for _, uplink := range status.Uplinks {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%s\t%s\t%s\t%s\n", uplink.Name,
+			orDash(strings.Join(uplink.Families, ",")), uplink.Routes, uplink.Optimized, uplink.Unchanged,
+			uplink.Skipped, uplink.Failed, orDash(route.JoinInts(uplink.MTU)), orDash(strings.Join(uplink.Congctl, ",")),
+			orDash(route.JoinInts(uplink.InitCwnd)), orDash(route.JoinInts(uplink.InitRwnd)))
+	}
+	return w.Flush()
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func statusCommand() {
+	if err := runStatus(os.Stdout, traffic.DefaultStatusPath); err != nil {
+		fmt.Fprintf(os.Stderr, "tcsss status: %v\n", err)
+		os.Exit(1)
+	}
+}
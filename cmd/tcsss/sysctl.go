@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"tcsss/internal/syslimit"
+)
+
+// runSysctlRollback restores the sysctl values and drop-in recorded before tcsss changed them.
+func runSysctlRollback(out io.Writer, stateDir string) error {
+	snapshot, results, err := syslimit.RollbackSysctl(stateDir)
+	if len(results) > 0 {
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tRESTORED\tSTATUS\tDETAIL")
+		for _, result := range results {
+			detail := result.Error
+			if detail == "" {
+				detail = result.Reason
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Key, result.Want, result.Status, orDash(detail))
+		}
+		if flushErr := w.Flush(); flushErr != nil {
+			return flushErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	drop := "removed"
+	if snapshot.DropIn != nil {
+		drop = "restored"
+	}
+	fmt.Fprintf(out, "snapshot from %s rolled back; %s %s\n",
+		snapshot.TakenAt.Local().Format("2006-01-02 15:04:05"), snapshot.Path, drop)
+	return nil
+}
+
+func sysctlCommand(args []string) {
+	if len(args) != 1 || args[0] != "rollback" {
+		fmt.Fprintln(os.Stderr, "usage: tcsss sysctl rollback")
+		os.Exit(2)
+	}
+	if err := runSysctlRollback(os.Stdout, syslimit.DefaultStateDir); err != nil {
+		fmt.Fprintf(os.Stderr, "tcsss sysctl rollback: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,186 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tmpl "tcsss/internal/config"
+)
+
+// ConfigFile is the optional alert template read from the configuration directory.
+const ConfigFile = "alerts.conf"
+
+const (
+	defaultInterval       = 10 * time.Second
+	defaultWebhookTimeout = 5 * time.Second
+	defaultExecTimeout    = 10 * time.Second
+	// AnyInterface matches every interface reported by the shaper.
+	AnyInterface = "*"
+)
+
+// Config holds alert rules and notifier targets.
+type Config struct {
+	Interval       time.Duration
+	Rules          []Rule
+	Webhook        string
+	WebhookTimeout time.Duration
+	Exec           string
+	ExecTimeout    time.Duration
+	Log            bool
+}
+
+// Rule fires when Metric on Interface compares true against Threshold for at least For.
+type Rule struct {
+	Name      string
+	Interface string
+	Metric    string
+	// Tin restricts CAKE tin metrics to a single tin (bulk, besteffort, video, voice, tinN).
+	Tin       string
+	Op        string
+	Threshold float64
+	For       time.Duration
+}
+
+// Enabled reports whether at least one rule and one notifier are configured.
+func (c Config) Enabled() bool {
+	return len(c.Rules) > 0 && (c.Webhook != "" || c.Exec != "" || c.Log)
+}
+
+// LoadConfig reads alerts.conf from the template directory. A missing file disables alerting.
+func LoadConfig(templateDir string) (Config, error) {
+	data, err := os.ReadFile(filepath.Join(templateDir, ConfigFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Config{}, nil
+		}
+		return Config{}, fmt.Errorf("read %s: %w", ConfigFile, err)
+	}
+	return ParseConfig(string(data))
+}
+
+// ParseConfig parses key = value alert settings:
+//
+//	interval = 10s
+//	rule.<name> = <iface|*> <metric>[@<tin>] <op> <threshold> [for <duration>]
+//	notify.webhook = https://example.com/hook
+//	notify.webhook_timeout = 5s
+//	notify.exec = /usr/local/bin/alert-hook
+//	notify.exec_timeout = 10s
+//	notify.log = true
+func ParseConfig(content string) (Config, error) {
+	cfg := Config{
+		Interval:       defaultInterval,
+		WebhookTimeout: defaultWebhookTimeout,
+		ExecTimeout:    defaultExecTimeout,
+	}
+
+	for lineNo, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return Config{}, fmt.Errorf("line %d: expected key = value", lineNo+1)
+		}
+		key := strings.TrimSpace(parts[0])
+		value := tmpl.StripInlineComment(parts[1])
+
+		var err error
+		switch {
+		case key == "interval":
+			cfg.Interval, err = time.ParseDuration(value)
+		case key == "notify.webhook":
+			cfg.Webhook = value
+		case key == "notify.webhook_timeout":
+			cfg.WebhookTimeout, err = time.ParseDuration(value)
+		case key == "notify.exec":
+			cfg.Exec = value
+		case key == "notify.exec_timeout":
+			cfg.ExecTimeout, err = time.ParseDuration(value)
+		case key == "notify.log":
+			cfg.Log, err = strconv.ParseBool(value)
+		case strings.HasPrefix(key, "rule."):
+			var rule Rule
+			rule, err = parseRule(strings.TrimPrefix(key, "rule."), value)
+			cfg.Rules = append(cfg.Rules, rule)
+		default:
+			err = fmt.Errorf("unknown key %q", key)
+		}
+		if err != nil {
+			return Config{}, fmt.Errorf("line %d: %w", lineNo+1, err)
+		}
+	}
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.WebhookTimeout <= 0 {
+		cfg.WebhookTimeout = defaultWebhookTimeout
+	}
+	if cfg.ExecTimeout <= 0 {
+		cfg.ExecTimeout = defaultExecTimeout
+	}
+	sort.Slice(cfg.Rules, func(i, j int) bool {
+		return cfg.Rules[i].Name < cfg.Rules[j].Name
+	})
+	return cfg, nil
+}
+
+func parseRule(name, value string) (Rule, error) {
+	if name == "" {
+		return Rule{}, errors.New("rule name must not be empty")
+	}
+	fields := strings.Fields(value)
+	if len(fields) != 4 && len(fields) != 6 {
+		return Rule{}, fmt.Errorf("rule %s: expected \"<iface> <metric> <op> <threshold> [for <duration>]\"", name)
+	}
+
+	rule := Rule{Name: name, Interface: fields[0], Op: fields[2]}
+	rule.Metric, rule.Tin, _ = strings.Cut(fields[1], "@")
+	if _, ok := metrics[rule.Metric]; !ok {
+		return Rule{}, fmt.Errorf("rule %s: unknown metric %q", name, rule.Metric)
+	}
+	if rule.Tin != "" && !metrics[rule.Metric].perTin {
+		return Rule{}, fmt.Errorf("rule %s: metric %s has no per-tin value", name, rule.Metric)
+	}
+	if _, ok := comparators[rule.Op]; !ok {
+		return Rule{}, fmt.Errorf("rule %s: unknown operator %q", name, rule.Op)
+	}
+
+	threshold, err := parseThreshold(fields[3])
+	if err != nil {
+		return Rule{}, fmt.Errorf("rule %s: %w", name, err)
+	}
+	rule.Threshold = threshold
+
+	if len(fields) == 6 {
+		if fields[4] != "for" {
+			return Rule{}, fmt.Errorf("rule %s: expected \"for\", got %q", name, fields[4])
+		}
+		rule.For, err = time.ParseDuration(fields[5])
+		if err != nil {
+			return Rule{}, fmt.Errorf("rule %s: %w", name, err)
+		}
+	}
+	return rule, nil
+}
+
+// parseThreshold accepts plain numbers or durations; durations are expressed in milliseconds
+// to match the delay metrics.
+func parseThreshold(value string) (float64, error) {
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return n, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid threshold %q", value)
+	}
+	return float64(d) / float64(time.Millisecond), nil
+}
@@ -0,0 +1,105 @@
+package alert
+
+import (
+	"time"
+
+	"tcsss/internal/traffic"
+)
+
+// sample is everything known about one interface at a single evaluation.
+type sample struct {
+	qdisc  *traffic.QdiscStats
+	health *traffic.InterfaceStatus
+}
+
+// metricDef extracts a value from a sample. Counter metrics return the raw counter and
+// are turned into per-second rates by the evaluator.
+type metricDef struct {
+	perTin  bool
+	counter bool
+	value   func(s sample, tin string) (float64, bool)
+}
+
+var metrics = map[string]metricDef{
+	"drops_per_sec": {perTin: true, counter: true, value: func(s sample, tin string) (float64, bool) {
+		if tin == "" && s.qdisc != nil {
+			return float64(s.qdisc.Drops), true
+		}
+		return tinValue(s, tin, func(t traffic.CakeTinStats) float64 { return float64(t.Drops) }, sum)
+	}},
+	"marks_per_sec": {perTin: true, counter: true, value: func(s sample, tin string) (float64, bool) {
+		return tinValue(s, tin, func(t traffic.CakeTinStats) float64 { return float64(t.ECNMarks) }, sum)
+	}},
+	"ack_drops_per_sec": {perTin: true, counter: true, value: func(s sample, tin string) (float64, bool) {
+		return tinValue(s, tin, func(t traffic.CakeTinStats) float64 { return float64(t.AckDrops) }, sum)
+	}},
+	"backlog_bytes": {perTin: true, value: func(s sample, tin string) (float64, bool) {
+		if tin == "" && s.qdisc != nil {
+			return float64(s.qdisc.Backlog), true
+		}
+		return tinValue(s, tin, func(t traffic.CakeTinStats) float64 { return float64(t.BacklogBytes) }, sum)
+	}},
+	"qlen": {value: func(s sample, _ string) (float64, bool) {
+		if s.qdisc == nil {
+			return 0, false
+		}
+		return float64(s.qdisc.Qlen), true
+	}},
+	"avg_delay": {perTin: true, value: func(s sample, tin string) (float64, bool) {
+		return tinValue(s, tin, func(t traffic.CakeTinStats) float64 { return milliseconds(t.AvgDelay) }, maximum)
+	}},
+	"peak_delay": {perTin: true, value: func(s sample, tin string) (float64, bool) {
+		return tinValue(s, tin, func(t traffic.CakeTinStats) float64 { return milliseconds(t.PeakDelay) }, maximum)
+	}},
+	"degraded": {value: func(s sample, _ string) (float64, bool) {
+		if s.health == nil {
+			return 0, false
+		}
+		if s.health.State == traffic.HealthDegraded {
+			return 1, true
+		}
+		return 0, true
+	}},
+	"failures": {value: func(s sample, _ string) (float64, bool) {
+		if s.health == nil {
+			return 0, false
+		}
+		return float64(s.health.Failures), true
+	}},
+}
+
+var comparators = map[string]func(value, threshold float64) bool{
+	">":  func(v, t float64) bool { return v > t },
+	">=": func(v, t float64) bool { return v >= t },
+	"<":  func(v, t float64) bool { return v < t },
+	"<=": func(v, t float64) bool { return v <= t },
+	"==": func(v, t float64) bool { return v == t },
+	"!=": func(v, t float64) bool { return v != t },
+}
+
+// tinValue reads a per-tin value, or combines all tins when no tin is named.
+func tinValue(s sample, tin string, get func(traffic.CakeTinStats) float64, combine func(a, b float64) float64) (float64, bool) {
+	if s.qdisc == nil || s.qdisc.Cake == nil || len(s.qdisc.Cake.Tins) == 0 {
+		return 0, false
+	}
+	if tin != "" {
+		t := s.qdisc.Cake.Tin(tin)
+		if t == nil {
+			return 0, false
+		}
+		return get(*t), true
+	}
+	result := get(s.qdisc.Cake.Tins[0])
+	for _, t := range s.qdisc.Cake.Tins[1:] {
+		result = combine(result, get(t))
+	}
+	return result, true
+}
+
+func sum(a, b float64) float64 { return a + b }
+
+func maximum(a, b float64) float64 { return max(a, b) }
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
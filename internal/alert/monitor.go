@@ -0,0 +1,217 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+
+	"tcsss/internal/traffic"
+)
+
+// Source provides the queue statistics and interface health evaluated by the rules.
+type Source interface {
+	CollectQueueStats(ctx context.Context) ([]traffic.QueueStats, error)
+	Status() traffic.Status
+}
+
+// Monitor periodically evaluates alert rules and notifies on firing and recovery.
+// A rule notifies once when it starts firing and once when it resolves; it stays silent in between.
+type Monitor struct {
+	logger    *slog.Logger
+	cfg       Config
+	source    Source
+	notifiers []Notifier
+	host      string
+	states    map[string]*ruleState
+	counters  map[string]counterSample
+}
+
+type ruleState struct {
+	rule         Rule
+	iface        string
+	pendingSince time.Time
+	firing       bool
+	firingSince  time.Time
+}
+
+type counterSample struct {
+	value float64
+	at    time.Time
+}
+
+// NewMonitor constructs an alert Monitor.
+func NewMonitor(logger *slog.Logger, cfg Config, source Source) *Monitor {
+	host, _ := os.Hostname()
+	return &Monitor{
+		logger:    logger,
+		cfg:       cfg,
+		source:    source,
+		notifiers: newNotifiers(logger, cfg),
+		host:      host,
+		states:    make(map[string]*ruleState),
+		counters:  make(map[string]counterSample),
+	}
+}
+
+// Run evaluates rules every interval until the context is cancelled.
+func (m *Monitor) Run(ctx context.Context) error {
+	if m.logger != nil {
+		m.logger.Info("alert monitor started",
+			slog.Int("rules", len(m.cfg.Rules)),
+			slog.Int("notifiers", len(m.notifiers)),
+			slog.Duration("interval", m.cfg.Interval))
+	}
+
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			m.evaluate(ctx, time.Now())
+		}
+	}
+}
+
+func (m *Monitor) evaluate(ctx context.Context, now time.Time) {
+	samples := m.collect(ctx)
+	seen := make(map[string]struct{})
+
+	for _, rule := range m.cfg.Rules {
+		def := metrics[rule.Metric]
+		compare := comparators[rule.Op]
+		for iface, s := range samples {
+			if rule.Interface != AnyInterface && rule.Interface != iface {
+				continue
+			}
+			key := rule.Name + "|" + iface
+			value, ok := def.value(s, rule.Tin)
+			if !ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			if def.counter {
+				value, ok = m.rate(key, value, now)
+				if !ok {
+					continue
+				}
+			}
+			m.transition(ctx, rule, iface, key, value, compare(value, rule.Threshold), now)
+		}
+	}
+
+	// Interfaces that disappeared resolve their alerts and drop their counters.
+	for key, state := range m.states {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		if state.firing {
+			m.notify(ctx, eventFor(state.rule, state.iface, m.host, StateResolved, 0, state.firingSince, now))
+		}
+		delete(m.states, key)
+	}
+	for key := range m.counters {
+		if _, ok := seen[key]; !ok {
+			delete(m.counters, key)
+		}
+	}
+}
+
+// collect maps interface names to samples; IFB mirrors are reported under their own name.
+func (m *Monitor) collect(ctx context.Context) map[string]sample {
+	samples := make(map[string]sample)
+	stats, err := m.source.CollectQueueStats(ctx)
+	if err != nil && m.logger != nil && !errors.Is(err, context.Canceled) {
+		m.logger.Debug("queue statistics incomplete", slog.String("error", err.Error()))
+	}
+	for _, qs := range stats {
+		if qs.Egress != nil {
+			samples[qs.Interface] = sample{qdisc: qs.Egress}
+		}
+		if qs.Ingress != nil && qs.IfbName != "" {
+			samples[qs.IfbName] = sample{qdisc: qs.Ingress}
+		}
+	}
+
+	status := m.source.Status()
+	for i := range status.Interfaces {
+		health := &status.Interfaces[i]
+		s := samples[health.Name]
+		s.health = health
+		samples[health.Name] = s
+	}
+	return samples
+}
+
+// rate converts a counter into a per-second rate against the previous evaluation.
+func (m *Monitor) rate(key string, value float64, now time.Time) (float64, bool) {
+	previous, ok := m.counters[key]
+	m.counters[key] = counterSample{value: value, at: now}
+	if !ok || value < previous.value {
+		return 0, false
+	}
+	elapsed := now.Sub(previous.at).Seconds()
+	if elapsed <= 0 {
+		return 0, false
+	}
+	return (value - previous.value) / elapsed, true
+}
+
+func (m *Monitor) transition(ctx context.Context, rule Rule, iface, key string, value float64, breached bool, now time.Time) {
+	state, ok := m.states[key]
+	if !ok {
+		state = &ruleState{rule: rule, iface: iface}
+		m.states[key] = state
+	}
+
+	if !breached {
+		state.pendingSince = time.Time{}
+		if state.firing {
+			state.firing = false
+			m.notify(ctx, eventFor(rule, iface, m.host, StateResolved, value, state.firingSince, now))
+		}
+		return
+	}
+
+	if state.pendingSince.IsZero() {
+		state.pendingSince = now
+	}
+	if state.firing || now.Sub(state.pendingSince) < rule.For {
+		return
+	}
+	state.firing = true
+	state.firingSince = state.pendingSince
+	m.notify(ctx, eventFor(rule, iface, m.host, StateFiring, value, state.firingSince, now))
+}
+
+func (m *Monitor) notify(ctx context.Context, event Event) {
+	for _, notifier := range m.notifiers {
+		if err := notifier.Notify(ctx, event); err != nil && m.logger != nil {
+			m.logger.Warn("alert notification failed",
+				slog.String("notifier", notifier.Name()),
+				slog.String("rule", event.Rule),
+				slog.String("state", event.State),
+				slog.String("error", err.Error()))
+		}
+	}
+}
+
+func eventFor(rule Rule, iface, host, state string, value float64, since, at time.Time) Event {
+	return Event{
+		Rule:      rule.Name,
+		State:     state,
+		Host:      host,
+		Interface: iface,
+		Metric:    rule.Metric,
+		Tin:       rule.Tin,
+		Op:        rule.Op,
+		Threshold: rule.Threshold,
+		Value:     value,
+		Since:     since,
+		At:        at,
+	}
+}
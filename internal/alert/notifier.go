@@ -0,0 +1,149 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// Alert states carried by Event.State.
+const (
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+// Event describes a rule transition delivered to notifiers.
+type Event struct {
+	Rule      string    `json:"rule"`
+	State     string    `json:"state"`
+	Host      string    `json:"host"`
+	Interface string    `json:"interface"`
+	Metric    string    `json:"metric"`
+	Tin       string    `json:"tin,omitempty"`
+	Op        string    `json:"op"`
+	Threshold float64   `json:"threshold"`
+	Value     float64   `json:"value"`
+	Since     time.Time `json:"since"`
+	At        time.Time `json:"at"`
+}
+
+// Notifier delivers alert events.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, event Event) error
+}
+
+// newNotifiers builds the notifiers enabled in the configuration.
+func newNotifiers(logger *slog.Logger, cfg Config) []Notifier {
+	var notifiers []Notifier
+	if cfg.Log {
+		notifiers = append(notifiers, logNotifier{logger: logger})
+	}
+	if cfg.Webhook != "" {
+		notifiers = append(notifiers, webhookNotifier{
+			url:    cfg.Webhook,
+			client: &http.Client{Timeout: cfg.WebhookTimeout},
+		})
+	}
+	if cfg.Exec != "" {
+		notifiers = append(notifiers, execNotifier{path: cfg.Exec, timeout: cfg.ExecTimeout})
+	}
+	return notifiers
+}
+
+type logNotifier struct {
+	logger *slog.Logger
+}
+
+func (logNotifier) Name() string { return "log" }
+
+func (n logNotifier) Notify(_ context.Context, event Event) error {
+	if n.logger == nil {
+		return nil
+	}
+	level := slog.LevelWarn
+	if event.State == StateResolved {
+		level = slog.LevelInfo
+	}
+	n.logger.Log(context.Background(), level, "alert "+event.State,
+		slog.String("rule", event.Rule),
+		slog.String("interface", event.Interface),
+		slog.String("metric", event.Metric),
+		slog.String("tin", event.Tin),
+		slog.String("op", event.Op),
+		slog.Float64("threshold", event.Threshold),
+		slog.Float64("value", event.Value),
+		slog.Time("since", event.Since))
+	return nil
+}
+
+// webhookNotifier POSTs the event as JSON.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (webhookNotifier) Name() string { return "webhook" }
+
+func (n webhookNotifier) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// execNotifier runs a hook with the event as JSON on stdin and as TCSSS_ALERT_* variables.
+type execNotifier struct {
+	path    string
+	timeout time.Duration
+}
+
+func (execNotifier) Name() string { return "exec" }
+
+func (n execNotifier) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, n.path)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"TCSSS_ALERT_RULE="+event.Rule,
+		"TCSSS_ALERT_STATE="+event.State,
+		"TCSSS_ALERT_INTERFACE="+event.Interface,
+		"TCSSS_ALERT_METRIC="+event.Metric,
+		"TCSSS_ALERT_TIN="+event.Tin,
+		"TCSSS_ALERT_VALUE="+strconv.FormatFloat(event.Value, 'f', -1, 64),
+		"TCSSS_ALERT_THRESHOLD="+strconv.FormatFloat(event.Threshold, 'f', -1, 64),
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("run %s: %w (output: %s)", n.path, err, bytes.TrimSpace(output))
+	}
+	return nil
+}
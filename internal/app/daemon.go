@@ -31,12 +31,18 @@ type TrafficService interface {
 	Watch(ctx context.Context) error
 }
 
+// MonitorService defines an optional background monitor such as alerting.
+type MonitorService interface {
+	Run(ctx context.Context) error
+}
+
 // Dependencies groups the external services required by the daemon.
 type Dependencies struct {
 	SysctlApplier  SysctlService
 	RlimitApplier  RlimitService
 	LimitsApplier  LimitsService
 	TrafficManager TrafficService
+	AlertMonitor   MonitorService
 	Logger         *slog.Logger
 }
 
@@ -46,6 +52,7 @@ type Daemon struct {
 	rlimitApplier  RlimitService
 	limitsApplier  LimitsService
 	trafficManager TrafficService
+	alertMonitor   MonitorService
 	logger         *slog.Logger
 }
 
@@ -59,6 +66,7 @@ func NewDaemon(deps Dependencies) *Daemon {
 		rlimitApplier:  deps.RlimitApplier,
 		limitsApplier:  deps.LimitsApplier,
 		trafficManager: deps.TrafficManager,
+		alertMonitor:   deps.AlertMonitor,
 		logger:         deps.Logger,
 	}
 }
@@ -133,6 +141,18 @@ func (d *Daemon) Run(ctx context.Context) (err error) {
 		}()
 	}
 
+	// Priority 5: Optional alert monitoring
+	// Failures are logged and never stop the daemon
+	if d.alertMonitor != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.alertMonitor.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				d.logger.Warn("alert monitor stopped", slog.String("error", err.Error()))
+			}
+		}()
+	}
+
 	select {
 	case <-ctx.Done():
 	case err := <-watchErrs:
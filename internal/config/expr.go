@@ -0,0 +1,327 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidTemplate marks templates whose {{ ... }} expressions or #! headers are invalid.
+var ErrInvalidTemplate = errors.New("invalid template")
+
+// exprValue is a number, or a string for MODE and quoted literals.
+type exprValue struct {
+	num   float64
+	str   string
+	isStr bool
+}
+
+// ExpandTemplate replaces every {{ expr }} in a template with its value. Comment lines are left
+// alone so they can document expressions. Numbers are rounded to integers.
+func ExpandTemplate(content string, vars TemplateVars) (string, error) {
+	if !strings.Contains(content, "{{") {
+		return content, nil
+	}
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		expanded, err := expandLine(line, vars)
+		if err != nil {
+			return "", fmt.Errorf("%w: line %d: %v", ErrInvalidTemplate, i+1, err)
+		}
+		lines[i] = expanded
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func expandLine(line string, vars TemplateVars) (string, error) {
+	var b strings.Builder
+	rest := line
+	for {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			b.WriteString(rest)
+			return b.String(), nil
+		}
+		end := strings.Index(rest[start:], "}}")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated {{ in %q", line)
+		}
+		b.WriteString(rest[:start])
+
+		expr := rest[start+2 : start+end]
+		value, err := evaluate(expr, vars)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(value.String())
+		rest = rest[start+end+2:]
+	}
+}
+
+// EvaluateNumber evaluates an expression that must produce a number, such as the bare window
+// sizes of the traffic templates.
+func EvaluateNumber(expr string, vars TemplateVars) (float64, error) {
+	value, err := evaluate(expr, vars)
+	if err != nil {
+		return 0, err
+	}
+	if value.isStr {
+		return 0, fmt.Errorf("%q is not a number", expr)
+	}
+	return value.num, nil
+}
+
+func (v exprValue) String() string {
+	if v.isStr {
+		return v.str
+	}
+	return strconv.FormatInt(int64(math.Round(v.num)), 10)
+}
+
+func evaluate(expr string, vars TemplateVars) (exprValue, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return exprValue{}, err
+	}
+	if len(tokens) == 0 {
+		return exprValue{}, fmt.Errorf("empty expression")
+	}
+	p := &exprParser{tokens: tokens, vars: vars.values()}
+	value, err := p.comparison()
+	if err != nil {
+		return exprValue{}, fmt.Errorf("%q: %w", strings.TrimSpace(expr), err)
+	}
+	if !p.done() {
+		return exprValue{}, fmt.Errorf("%q: unexpected %q", strings.TrimSpace(expr), p.peek())
+	}
+	return value, nil
+}
+
+// tokenize splits an expression into numbers, identifiers, quoted strings and operators.
+func tokenize(expr string) ([]string, error) {
+	var tokens []string
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		case r == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != '"' {
+				j++
+			}
+			if j == len(runes) {
+				return nil, fmt.Errorf("unterminated string in %q", expr)
+			}
+			tokens = append(tokens, string(runes[i:j+1]))
+			i = j + 1
+		case (r == '=' || r == '!') && i+1 < len(runes) && runes[i+1] == '=':
+			tokens = append(tokens, string(runes[i:i+2]))
+			i += 2
+		case strings.ContainsRune("+-*/(),", r):
+			tokens = append(tokens, string(r))
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected %q in %q", r, expr)
+		}
+	}
+	return tokens, nil
+}
+
+// exprParser evaluates while parsing, with the usual precedence: comparisons bind loosest,
+// then + and -, then * and /, then unary minus.
+type exprParser struct {
+	tokens []string
+	pos    int
+	vars   map[string]exprValue
+}
+
+func (p *exprParser) done() bool { return p.pos >= len(p.tokens) }
+
+func (p *exprParser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *exprParser) comparison() (exprValue, error) {
+	left, err := p.sum()
+	if err != nil {
+		return left, err
+	}
+	op := p.peek()
+	if op != "==" && op != "!=" {
+		return left, nil
+	}
+	p.next()
+	right, err := p.sum()
+	if err != nil {
+		return right, err
+	}
+	if left.isStr != right.isStr {
+		return exprValue{}, fmt.Errorf("cannot compare a string with a number")
+	}
+	equal := left.str == right.str && left.num == right.num
+	if (op == "==") == equal {
+		return exprValue{num: 1}, nil
+	}
+	return exprValue{num: 0}, nil
+}
+
+func (p *exprParser) sum() (exprValue, error) {
+	left, err := p.product()
+	if err != nil {
+		return left, err
+	}
+	for p.peek() == "+" || p.peek() == "-" {
+		op := p.next()
+		right, err := p.product()
+		if err != nil {
+			return right, err
+		}
+		if left.isStr || right.isStr {
+			return exprValue{}, fmt.Errorf("%s needs numbers", op)
+		}
+		if op == "+" {
+			left.num += right.num
+		} else {
+			left.num -= right.num
+		}
+	}
+	return left, nil
+}
+
+func (p *exprParser) product() (exprValue, error) {
+	left, err := p.unary()
+	if err != nil {
+		return left, err
+	}
+	for p.peek() == "*" || p.peek() == "/" {
+		op := p.next()
+		right, err := p.unary()
+		if err != nil {
+			return right, err
+		}
+		if left.isStr || right.isStr {
+			return exprValue{}, fmt.Errorf("%s needs numbers", op)
+		}
+		if op == "*" {
+			left.num *= right.num
+			continue
+		}
+		if right.num == 0 {
+			return exprValue{}, fmt.Errorf("division by zero")
+		}
+		left.num /= right.num
+	}
+	return left, nil
+}
+
+func (p *exprParser) unary() (exprValue, error) {
+	if p.peek() != "-" {
+		return p.primary()
+	}
+	p.next()
+	value, err := p.unary()
+	if err != nil {
+		return value, err
+	}
+	if value.isStr {
+		return exprValue{}, fmt.Errorf("- needs a number")
+	}
+	value.num = -value.num
+	return value, nil
+}
+
+func (p *exprParser) primary() (exprValue, error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return exprValue{}, fmt.Errorf("unexpected end of expression")
+	case token == "(":
+		value, err := p.comparison()
+		if err != nil {
+			return value, err
+		}
+		if p.next() != ")" {
+			return exprValue{}, fmt.Errorf("missing )")
+		}
+		return value, nil
+	case strings.HasPrefix(token, `"`):
+		return exprValue{str: strings.Trim(token, `"`), isStr: true}, nil
+	case unicode.IsDigit(rune(token[0])) || token[0] == '.':
+		num, err := strconv.ParseFloat(strings.ReplaceAll(token, "_", ""), 64)
+		if err != nil {
+			return exprValue{}, fmt.Errorf("invalid number %q", token)
+		}
+		return exprValue{num: num}, nil
+	case token == "min" || token == "max":
+		return p.call(token)
+	}
+	if value, ok := p.vars[token]; ok {
+		return value, nil
+	}
+	return exprValue{}, fmt.Errorf("unknown variable %q", token)
+}
+
+// call evaluates min(...) and max(...) over one or more numbers.
+func (p *exprParser) call(name string) (exprValue, error) {
+	if p.next() != "(" {
+		return exprValue{}, fmt.Errorf("%s needs arguments", name)
+	}
+	var result exprValue
+	for n := 0; ; n++ {
+		arg, err := p.comparison()
+		if err != nil {
+			return arg, err
+		}
+		if arg.isStr {
+			return exprValue{}, fmt.Errorf("%s needs numbers", name)
+		}
+		switch {
+		case n == 0:
+			result = arg
+		case name == "min":
+			result.num = math.Min(result.num, arg.num)
+		default:
+			result.num = math.Max(result.num, arg.num)
+		}
+		switch p.next() {
+		case ",":
+			continue
+		case ")":
+			return result, nil
+		default:
+			return exprValue{}, fmt.Errorf("missing ) after %s arguments", name)
+		}
+	}
+}
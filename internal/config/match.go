@@ -0,0 +1,222 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// headerPrefix starts the match criteria lines of a limits_*.conf template.
+const headerPrefix = "#!"
+
+// HostFacts are the machine properties template headers are matched against.
+type HostFacts struct {
+	MemoryMB          float64
+	EffectiveMemoryMB float64
+	CPUs              int
+	LinkSpeedMbps     int
+	Virtualized       bool
+}
+
+// templateCriteria are the conditions a template declares in its #! header. Zero bounds are
+// open; a template matches only when every declared condition holds. Tiers named by size
+// compare against effective memory, as they always have.
+type templateCriteria struct {
+	hasMemory, effectiveMemory bool
+	memoryMinMB, memoryMaxMB   float64
+	hasCPUs                    bool
+	cpuMin, cpuMax             int
+	maxLinkMbps                int
+	hasVirt, virtualized       bool
+}
+
+// parseCriteria reads the #! header lines of a template, for example
+//
+//	#! memory = 2gb-8gb
+//	#! cpus = 1-4
+//	#! max_link_speed = 1000
+//	#! virtualized = yes
+func parseCriteria(content string) (templateCriteria, bool, error) {
+	var c templateCriteria
+	found := false
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, headerPrefix) {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, headerPrefix), "=")
+		if !ok {
+			return c, false, fmt.Errorf("invalid header %q", line)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.ToLower(strings.TrimSpace(value))
+		found = true
+
+		switch key {
+		case "memory":
+			low, high, err := parseRange(value, parseMemoryMB)
+			if err != nil {
+				return c, false, fmt.Errorf("memory: %w", err)
+			}
+			c.hasMemory, c.memoryMinMB, c.memoryMaxMB = true, low, high
+		case "cpus":
+			low, high, err := parseRange(value, func(s string) (float64, error) {
+				n, err := strconv.Atoi(s)
+				return float64(n), err
+			})
+			if err != nil {
+				return c, false, fmt.Errorf("cpus: %w", err)
+			}
+			c.hasCPUs, c.cpuMin, c.cpuMax = true, int(low), int(high)
+		case "max_link_speed":
+			speed, err := strconv.Atoi(strings.TrimSuffix(value, "mbps"))
+			if err != nil || speed <= 0 {
+				return c, false, fmt.Errorf("max_link_speed: invalid speed %q", value)
+			}
+			c.maxLinkMbps = speed
+		case "virtualized":
+			switch value {
+			case "yes", "true":
+				c.virtualized = true
+			case "no", "false":
+				c.virtualized = false
+			default:
+				return c, false, fmt.Errorf("virtualized: expected yes or no, got %q", value)
+			}
+			c.hasVirt = true
+		default:
+			return c, false, fmt.Errorf("unknown header key %q", key)
+		}
+	}
+	return c, found, nil
+}
+
+// parseRange parses "low-high", "low-" or "-high"; a single value is an exact match.
+func parseRange(value string, parse func(string) (float64, error)) (float64, float64, error) {
+	lowText, highText, isRange := strings.Cut(value, "-")
+	if !isRange {
+		highText = lowText
+	}
+	var low, high float64
+	var err error
+	if lowText = strings.TrimSpace(lowText); lowText != "" {
+		if low, err = parse(lowText); err != nil {
+			return 0, 0, fmt.Errorf("invalid bound %q", lowText)
+		}
+	}
+	if highText = strings.TrimSpace(highText); highText != "" {
+		if high, err = parse(highText); err != nil {
+			return 0, 0, fmt.Errorf("invalid bound %q", highText)
+		}
+	}
+	if high > 0 && low > high {
+		return 0, 0, fmt.Errorf("empty range %q", value)
+	}
+	return low, high, nil
+}
+
+// parseMemoryMB converts sizes such as 512mb, 4gb or 1.5tb to MB.
+func parseMemoryMB(value string) (float64, error) {
+	for _, unit := range []struct {
+		suffix string
+		factor float64
+	}{{"tb", 1024 * 1024}, {"gb", 1024}, {"mb", 1}} {
+		if number, ok := strings.CutSuffix(value, unit.suffix); ok {
+			size, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+			if err != nil || size < 0 {
+				return 0, fmt.Errorf("invalid size %q", value)
+			}
+			return size * unit.factor, nil
+		}
+	}
+	return 0, fmt.Errorf("size %q needs a mb, gb or tb unit", value)
+}
+
+// match checks the criteria against the host. The score counts the conditions that held, so
+// more specific templates win; reasons explain each one, or the first that failed.
+func (c templateCriteria) match(facts HostFacts) (int, []string, bool) {
+	score := 0
+	var reasons []string
+	if c.hasMemory {
+		memory, label := facts.MemoryMB, "memory"
+		if c.effectiveMemory {
+			memory, label = facts.EffectiveMemoryMB, "effective memory"
+		}
+		bounds := formatBounds(c.memoryMinMB, c.memoryMaxMB, "MB")
+		if memory < c.memoryMinMB || (c.memoryMaxMB > 0 && memory > c.memoryMaxMB) {
+			return 0, []string{fmt.Sprintf("%s %.0fMB outside %s", label, memory, bounds)}, false
+		}
+		score++
+		reasons = append(reasons, fmt.Sprintf("%s %.0fMB within %s", label, memory, bounds))
+	}
+	if c.hasCPUs {
+		if facts.CPUs < c.cpuMin || (c.cpuMax > 0 && facts.CPUs > c.cpuMax) {
+			return 0, []string{fmt.Sprintf("%d cpus outside %s", facts.CPUs, formatBounds(float64(c.cpuMin), float64(c.cpuMax), ""))}, false
+		}
+		score++
+		reasons = append(reasons, fmt.Sprintf("%d cpus within %s", facts.CPUs, formatBounds(float64(c.cpuMin), float64(c.cpuMax), "")))
+	}
+	if c.maxLinkMbps > 0 {
+		// An unknown speed (virtio, tunnels) says nothing about the link, so it cannot match.
+		if facts.LinkSpeedMbps == 0 {
+			return 0, []string{fmt.Sprintf("link speed unknown, template wants up to %dMbps", c.maxLinkMbps)}, false
+		}
+		if facts.LinkSpeedMbps > c.maxLinkMbps {
+			return 0, []string{fmt.Sprintf("link %dMbps above %dMbps", facts.LinkSpeedMbps, c.maxLinkMbps)}, false
+		}
+		score++
+		reasons = append(reasons, fmt.Sprintf("link %dMbps up to %dMbps", facts.LinkSpeedMbps, c.maxLinkMbps))
+	}
+	if c.hasVirt {
+		if facts.Virtualized != c.virtualized {
+			return 0, []string{"virtualization " + virtLabel(facts.Virtualized) + ", template wants " + virtLabel(c.virtualized)}, false
+		}
+		score++
+		reasons = append(reasons, virtLabel(facts.Virtualized))
+	}
+	return score, reasons, true
+}
+
+func formatBounds(low, high float64, unit string) string {
+	switch {
+	case high == 0:
+		return fmt.Sprintf(">=%.0f%s", low, unit)
+	case low == high:
+		return fmt.Sprintf("%.0f%s", low, unit)
+	}
+	return fmt.Sprintf("%.0f-%.0f%s", low, high, unit)
+}
+
+func virtLabel(virtualized bool) string {
+	if virtualized {
+		return "virtualized"
+	}
+	return "bare metal"
+}
+
+// templateCandidate is a limits_*.conf template with the outcome of matching it.
+type templateCandidate struct {
+	config  MemoryTierConfig
+	score   int
+	reasons []string
+	matched bool
+}
+
+// rankCandidates orders matching candidates best first: higher score, then the larger memory
+// tier, then filename, so the choice never depends on directory order.
+func rankCandidates(candidates []templateCandidate) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.matched != b.matched {
+			return a.matched
+		}
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.config.MemoryMB != b.config.MemoryMB {
+			return a.config.MemoryMB > b.config.MemoryMB
+		}
+		return a.config.Filename < b.config.Filename
+	})
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"tcsss/internal/sysinfo"
+)
+
+// AutoTrafficMode selects the traffic mode from observed host behaviour instead of template files.
+const AutoTrafficMode = "auto"
+
+// ModeDecision records the traffic mode chosen from host behaviour and the evidence behind it.
+type ModeDecision struct {
+	Mode       TrafficMode
+	Reasons    []string
+	Sockets    sysinfo.TCPSocketSummary
+	Forwarding bool
+	NAT        string
+	// IgnoredLinks lists the container links whose NAT was not counted as gateway evidence.
+	IgnoredLinks []string
+}
+
+// DetectTrafficMode inspects TCP sockets, packet forwarding and NAT usage to pick a mode:
+// a forwarding host that translates addresses is an aggregate gateway, a host that mostly
+// accepts connections is a server, and everything else is a client. When the template of
+// the chosen mode is missing, the file-based selection is used instead.
+func DetectTrafficMode(templateDir string) (ModeDecision, error) {
+	sockets, err := sysinfo.ReadTCPSockets(sysinfo.ProcNetTCP, sysinfo.ProcNetTCP6)
+	if err != nil {
+		return ModeDecision{}, fmt.Errorf("inspect tcp sockets: %w", err)
+	}
+
+	nat := sysinfo.DetectNAT()
+	decision := ModeDecision{
+		Sockets:      sockets,
+		Forwarding:   sysinfo.ForwardingEnabled(),
+		NAT:          nat.Gateway,
+		IgnoredLinks: nat.IgnoredLinks,
+	}
+	decision.Mode, decision.Reasons = classifyHostRole(decision)
+
+	if _, err := os.Stat(filepath.Join(templateDir, trafficModeFiles[decision.Mode])); err != nil {
+		fallback, ferr := detectTrafficModeFromFiles(templateDir)
+		if ferr != nil {
+			return decision, ferr
+		}
+		decision.Reasons = append(decision.Reasons,
+			fmt.Sprintf("%s is missing, using %s", trafficModeFiles[decision.Mode], trafficModeFiles[fallback]))
+		decision.Mode = fallback
+	}
+	return decision, nil
+}
+
+func classifyHostRole(d ModeDecision) (TrafficMode, []string) {
+	var reasons []string
+	if d.Forwarding {
+		if d.NAT != "" {
+			return TrafficModeAggregate, []string{fmt.Sprintf("packet forwarding enabled with NAT (%s)", d.NAT)}
+		}
+		if len(d.IgnoredLinks) > 0 {
+			reasons = append(reasons, fmt.Sprintf("packet forwarding enabled with NAT only for container links (%s), not treated as a gateway",
+				strings.Join(d.IgnoredLinks, ", ")))
+		} else {
+			reasons = append(reasons, "packet forwarding enabled without NAT, not treated as a gateway")
+		}
+	}
+
+	s := d.Sockets
+	if s.Listening == 0 {
+		return TrafficModeClient, append(reasons, "no externally reachable listening sockets")
+	}
+	if s.AcceptQueue > 0 {
+		return TrafficModeServer, append(reasons,
+			fmt.Sprintf("%d listening sockets with %d connections waiting to be accepted", s.Listening, s.AcceptQueue))
+	}
+	if s.Inbound > 0 && s.Inbound >= s.Outbound {
+		return TrafficModeServer, append(reasons,
+			fmt.Sprintf("%d listening sockets, %d inbound vs %d outbound established connections", s.Listening, s.Inbound, s.Outbound))
+	}
+	return TrafficModeClient, append(reasons,
+		fmt.Sprintf("%d listening sockets but %d inbound vs %d outbound established connections", s.Listening, s.Inbound, s.Outbound))
+}
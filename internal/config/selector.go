@@ -7,8 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
-	"strconv"
 	"strings"
 
 	"tcsss/internal/sysinfo"
@@ -32,8 +32,13 @@ type TrafficInitConfig struct {
 	InitCwndBytes           int
 	InitRwndBytes           int
 	InitLoopbackWindowBytes int
+	// NICProfile names the ring/channel/coalescing preset (latency, balanced, throughput); empty disables it.
+	NICProfile string
 }
 
+// NICProfiles are the nicProfile presets the traffic shaper implements.
+var NICProfiles = []string{"latency", "balanced", "throughput"}
+
 var (
 	trafficModeFiles = map[TrafficMode]string{
 		TrafficModeClient:    "1-client.conf",
@@ -54,6 +59,7 @@ var (
 	}
 
 	memoryTierPattern = regexp.MustCompile(`^limits_([0-9]+\.?[0-9]*)(mb|gb|tb)\.conf$`)
+	limitsTemplate    = regexp.MustCompile(`^limits_([a-z0-9._-]+)\.conf$`)
 
 	defaultTrafficInitConfig = TrafficInitConfig{
 		Mode:                    TrafficModeClient,
@@ -69,6 +75,20 @@ type MemoryTierConfig struct {
 	MemoryLabel string
 	Filename    string
 	Content     string
+
+	sized    bool
+	criteria templateCriteria
+}
+
+// TemplateSelection explains why a limits template was chosen.
+type TemplateSelection struct {
+	Facts   HostFacts
+	Score   int
+	Reasons []string
+	// Rejected maps every other candidate to the reason it lost.
+	Rejected map[string]string
+	// Fallback is set when no template matched and the smallest tier was used.
+	Fallback bool
 }
 
 // TemplateSet bundles the selected templates for sysctl and limits generation.
@@ -78,6 +98,9 @@ type TemplateSet struct {
 	MemoryConfig      MemoryTierConfig
 	SystemMemoryGB    float64
 	EffectiveMemoryGB float64
+	// Vars are the values the {{ ... }} expressions of Common and Specific were expanded with.
+	Vars      TemplateVars
+	Selection TemplateSelection
 }
 
 // LoadTrafficInitConfig reads and parses the traffic tuning template for the requested mode.
@@ -99,12 +122,13 @@ func LoadTrafficInitConfig(templateDir, mode string) (TrafficInitConfig, error)
 		}
 	}
 
-	content, err := TrafficTemplateContent(templateDir, selectedMode)
+	vars := DetectTemplateVars(selectedMode)
+	content, err := trafficTemplateContent(templateDir, selectedMode, vars)
 	if err != nil {
 		return defaultTrafficInitConfig, err
 	}
 
-	cfg, err := parseTrafficTemplate(selectedMode, content)
+	cfg, err := parseTrafficTemplate(selectedMode, content, vars)
 	if err != nil {
 		return defaultTrafficInitConfig, err
 	}
@@ -112,19 +136,32 @@ func LoadTrafficInitConfig(templateDir, mode string) (TrafficInitConfig, error)
 	return cfg, nil
 }
 
-// TrafficTemplateContent returns the raw template content for the given traffic mode.
+// TrafficTemplateContent returns the template content for the given traffic mode with its
+// {{ ... }} expressions expanded.
 func TrafficTemplateContent(templateDir string, mode TrafficMode) (string, error) {
-	if filename, ok := trafficModeFiles[mode]; ok {
-		return readTemplateFile(templateDir, filename)
+	return trafficTemplateContent(templateDir, mode, DetectTemplateVars(mode))
+}
+
+func trafficTemplateContent(templateDir string, mode TrafficMode, vars TemplateVars) (string, error) {
+	filename, ok := trafficModeFiles[mode]
+	if !ok {
+		filename = trafficModeFiles[TrafficModeClient]
 	}
-	if filename, ok := trafficModeFiles[TrafficModeClient]; ok {
-		return readTemplateFile(templateDir, filename)
+	content, err := readTemplateFile(templateDir, filename)
+	if err != nil {
+		return "", err
+	}
+	expanded, err := ExpandTemplate(content, vars)
+	if err != nil {
+		return "", fmt.Errorf("expand %s: %w", filename, err)
 	}
-	return "", fmt.Errorf("traffic mode %q is not supported", mode)
+	return expanded, nil
 }
 
-// DetectTemplateSet selects the appropriate sysctl templates based on system memory.
-func DetectTemplateSet(templateDir string) (TemplateSet, error) {
+// DetectTemplateSet selects the limits template that best matches the machine (memory, CPUs,
+// link speed, virtualization) and expands the {{ ... }} expressions of the selected templates
+// for the machine and traffic mode.
+func DetectTemplateSet(templateDir string, mode TrafficMode) (TemplateSet, error) {
 	memKB, err := sysinfo.ReadMemoryKB("/proc/meminfo")
 	if err != nil {
 		return TemplateSet{}, fmt.Errorf("detect system memory: %w", err)
@@ -135,8 +172,18 @@ func DetectTemplateSet(templateDir string) (TemplateSet, error) {
 		return TemplateSet{}, err
 	}
 
+	vars := DetectTemplateVars(mode)
+	vars.MemKB = memKB
 	systemMemoryMB := float64(memKB) / 1024
-	selectedTier, effectiveMB, err := selectBestMemoryTier(systemMemoryMB, tiers)
+	effectiveMB := systemMemoryMB * MemoryEffectivenessFactor
+	facts := HostFacts{
+		MemoryMB:          systemMemoryMB,
+		EffectiveMemoryMB: effectiveMB,
+		CPUs:              vars.CPUs,
+		LinkSpeedMbps:     vars.LinkSpeedMbps,
+		Virtualized:       sysinfo.Virtualized(),
+	}
+	selectedTier, selection, err := selectTemplate(facts, tiers)
 	if err != nil {
 		return TemplateSet{}, err
 	}
@@ -150,12 +197,22 @@ func DetectTemplateSet(templateDir string) (TemplateSet, error) {
 		return TemplateSet{}, err
 	}
 
+	if commonContent, err = ExpandTemplate(commonContent, vars); err != nil {
+		return TemplateSet{}, fmt.Errorf("expand common.conf: %w", err)
+	}
+	specificContent, err := ExpandTemplate(selectedTier.Content, vars)
+	if err != nil {
+		return TemplateSet{}, fmt.Errorf("expand %s: %w", selectedTier.Filename, err)
+	}
+
 	return TemplateSet{
 		Common:            commonContent,
-		Specific:          selectedTier.Content,
+		Specific:          specificContent,
 		MemoryConfig:      selectedTier,
 		SystemMemoryGB:    systemMemoryMB / 1024,
 		EffectiveMemoryGB: effectiveMB / 1024,
+		Vars:              vars,
+		Selection:         selection,
 	}, nil
 }
 
@@ -202,7 +259,7 @@ func detectTrafficModeFromFiles(templateDir string) (TrafficMode, error) {
 	return bestMode, nil
 }
 
-func parseTrafficTemplate(mode TrafficMode, content string) (TrafficInitConfig, error) {
+func parseTrafficTemplate(mode TrafficMode, content string, vars TemplateVars) (TrafficInitConfig, error) {
 	cfg := TrafficInitConfig{
 		Mode: mode,
 	}
@@ -226,15 +283,23 @@ func parseTrafficTemplate(mode TrafficMode, content string) (TrafficInitConfig,
 		}
 
 		key := strings.TrimSpace(parts[0])
+		if key == "nicProfile" {
+			profile := strings.ToLower(StripInlineComment(parts[1]))
+			if profile != "" && !slices.Contains(NICProfiles, profile) {
+				return defaultTrafficInitConfig, fmt.Errorf("%w: nicProfile: unknown preset %q, expected one of %s", ErrInvalidTemplate, profile, strings.Join(NICProfiles, ", "))
+			}
+			cfg.NICProfile = profile
+			continue
+		}
 		ptr, ok := values[key]
 		if !ok {
 			continue
 		}
 
-		valueExpr := stripInlineComment(parts[1])
-		result, err := evaluateExpression(valueExpr)
+		valueExpr := StripInlineComment(parts[1])
+		result, err := evaluateExpression(valueExpr, vars)
 		if err != nil {
-			return defaultTrafficInitConfig, fmt.Errorf("parse %s: %w", key, err)
+			return defaultTrafficInitConfig, fmt.Errorf("%w: %s: %v", ErrInvalidTemplate, key, err)
 		}
 		*ptr = result
 	}
@@ -252,35 +317,30 @@ func parseTrafficTemplate(mode TrafficMode, content string) (TrafficInitConfig,
 	return cfg, nil
 }
 
-func stripInlineComment(value string) string {
+// StripInlineComment trims a template value and drops a trailing comment. A comment starts at a
+// "#" that begins the value or follows whitespace, so URL fragments and commands keep theirs.
+func StripInlineComment(value string) string {
 	v := strings.TrimSpace(value)
-	if idx := strings.Index(v, "#"); idx >= 0 {
-		v = v[:idx]
+	for i := 0; i < len(v); i++ {
+		if v[i] == '#' && (i == 0 || v[i-1] == ' ' || v[i-1] == '\t') {
+			v = v[:i]
+			break
+		}
 	}
 	return strings.TrimSpace(v)
 }
 
-func evaluateExpression(expr string) (int, error) {
+// evaluateExpression evaluates a window size, which may use the same arithmetic and variables
+// as {{ ... }} expressions without the braces.
+func evaluateExpression(expr string, vars TemplateVars) (int, error) {
 	expr = strings.TrimSpace(expr)
 	if expr == "" {
 		return 0, fmt.Errorf("empty expression")
 	}
 
-	factors := strings.Split(expr, "*")
-	result := 1.0
-
-	for _, factor := range factors {
-		part := strings.TrimSpace(factor)
-		if part == "" {
-			return 0, fmt.Errorf("invalid factor in %q", expr)
-		}
-
-		value, err := strconv.ParseFloat(part, 64)
-		if err != nil {
-			return 0, fmt.Errorf("invalid number %q: %w", part, err)
-		}
-
-		result *= value
+	result, err := EvaluateNumber(expr, vars)
+	if err != nil {
+		return 0, err
 	}
 
 	if result < 0 {
@@ -299,6 +359,9 @@ func readTemplateFile(templateDir, filename string) (string, error) {
 	return string(data), nil
 }
 
+// scanMemoryTierConfigs collects the limits_*.conf candidates: tiers named by size, which
+// implicitly require that much effective memory, and any other limits_<name>.conf carrying a
+// #! header. Headers are read for both.
 func scanMemoryTierConfigs(templateDir string) ([]MemoryTierConfig, error) {
 	entries, err := os.ReadDir(templateDir)
 	if err != nil {
@@ -313,38 +376,41 @@ func scanMemoryTierConfigs(templateDir string) ([]MemoryTierConfig, error) {
 		}
 
 		filename := strings.ToLower(entry.Name())
-		matches := memoryTierPattern.FindStringSubmatch(filename)
-		if matches == nil {
+		named := limitsTemplate.FindStringSubmatch(filename)
+		if named == nil {
 			continue
 		}
 
-		sizeStr := matches[1]
-		unit := matches[2]
+		config := MemoryTierConfig{MemoryLabel: named[1], Filename: entry.Name()}
+		if matches := memoryTierPattern.FindStringSubmatch(filename); matches != nil {
+			memoryMB, err := parseMemoryMB(matches[1] + matches[2])
+			if err != nil {
+				continue
+			}
+			config.MemoryMB = memoryMB
+			config.sized = true
+		}
 
-		size, err := strconv.ParseFloat(sizeStr, 64)
+		content, err := readTemplateFile(templateDir, entry.Name())
 		if err != nil {
-			continue
+			return nil, err
 		}
-
-		var memoryMB float64
-		switch unit {
-		case "mb":
-			memoryMB = size
-		case "gb":
-			memoryMB = size * 1024
-		case "tb":
-			memoryMB = size * 1024 * 1024
-		default:
+		criteria, hasHeader, err := parseCriteria(content)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s header: %v", ErrInvalidTemplate, entry.Name(), err)
+		}
+		if !config.sized && !hasHeader {
 			continue
 		}
-
-		label := fmt.Sprintf("%s%s", sizeStr, unit)
-
-		configs = append(configs, MemoryTierConfig{
-			MemoryMB:    memoryMB,
-			MemoryLabel: label,
-			Filename:    entry.Name(),
-		})
+		if config.sized && !criteria.hasMemory {
+			criteria.hasMemory, criteria.effectiveMemory, criteria.memoryMinMB = true, true, config.MemoryMB
+		}
+		if !config.sized {
+			config.MemoryMB = criteria.memoryMinMB
+		}
+		config.Content = content
+		config.criteria = criteria
+		configs = append(configs, config)
 	}
 
 	if len(configs) == 0 {
@@ -352,34 +418,64 @@ func scanMemoryTierConfigs(templateDir string) ([]MemoryTierConfig, error) {
 	}
 
 	sort.Slice(configs, func(i, j int) bool {
-		return configs[i].MemoryMB < configs[j].MemoryMB
+		if configs[i].MemoryMB != configs[j].MemoryMB {
+			return configs[i].MemoryMB < configs[j].MemoryMB
+		}
+		return configs[i].Filename < configs[j].Filename
 	})
 
 	return configs, nil
 }
 
-func selectBestMemoryTier(systemMemoryMB float64, tiers []MemoryTierConfig) (MemoryTierConfig, float64, error) {
+// selectTemplate matches every candidate against the host and picks the best one. When none
+// matches, the smallest tier named by size is used, as when memory alone decided.
+func selectTemplate(facts HostFacts, tiers []MemoryTierConfig) (MemoryTierConfig, TemplateSelection, error) {
+	selection := TemplateSelection{Facts: facts, Rejected: make(map[string]string)}
 	if len(tiers) == 0 {
-		return MemoryTierConfig{}, 0, errors.New("no memory tier configurations available")
+		return MemoryTierConfig{}, selection, errors.New("no memory tier configurations available")
 	}
 
-	if systemMemoryMB <= 0 {
-		return MemoryTierConfig{}, 0, fmt.Errorf("invalid system memory: %.2f MB", systemMemoryMB)
+	if facts.MemoryMB <= 0 {
+		return MemoryTierConfig{}, selection, fmt.Errorf("invalid system memory: %.2f MB", facts.MemoryMB)
 	}
 
-	if systemMemoryMB > MaximumSupportedMemoryMB {
-		return MemoryTierConfig{}, 0, fmt.Errorf("system memory %.2f MB exceeds supported range", systemMemoryMB)
+	if facts.MemoryMB > MaximumSupportedMemoryMB {
+		return MemoryTierConfig{}, selection, fmt.Errorf("system memory %.2f MB exceeds supported range", facts.MemoryMB)
 	}
 
-	effectiveMemoryMB := systemMemoryMB * MemoryEffectivenessFactor
+	candidates := make([]templateCandidate, 0, len(tiers))
+	for _, tier := range tiers {
+		score, reasons, matched := tier.criteria.match(facts)
+		candidates = append(candidates, templateCandidate{config: tier, score: score, reasons: reasons, matched: matched})
+	}
+	rankCandidates(candidates)
 
-	for i := len(tiers) - 1; i >= 0; i-- {
-		if tiers[i].MemoryMB <= effectiveMemoryMB {
-			return tiers[i], effectiveMemoryMB, nil
+	best := candidates[0]
+	if !best.matched {
+		for _, tier := range tiers {
+			if tier.sized {
+				best = templateCandidate{config: tier, reasons: []string{"no template matched; smallest tier used"}}
+				break
+			}
+		}
+		if !best.config.sized {
+			return MemoryTierConfig{}, selection, errors.New("no limits template matches this host")
 		}
+		selection.Fallback = true
 	}
 
-	return tiers[0], effectiveMemoryMB, nil
+	for _, candidate := range candidates {
+		switch {
+		case candidate.config.Filename == best.config.Filename:
+		case !candidate.matched:
+			selection.Rejected[candidate.config.Filename] = strings.Join(candidate.reasons, "; ")
+		default:
+			selection.Rejected[candidate.config.Filename] = fmt.Sprintf("matched with score %d, outranked", candidate.score)
+		}
+	}
+	selection.Score = best.score
+	selection.Reasons = best.reasons
+	return best.config, selection, nil
 }
 
 func loadMemoryTierContent(templateDir string, config *MemoryTierConfig) error {
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"os"
+	"runtime"
+
+	"tcsss/internal/sysinfo"
+)
+
+// TemplateVars are the machine facts template expressions can refer to.
+type TemplateVars struct {
+	MemKB    uint64
+	PageSize int
+	CPUs     int
+	// LinkSpeedMbps is the speed of the default route's link or its fastest port, or 0 when unknown.
+	LinkSpeedMbps int
+	Mode          TrafficMode
+}
+
+// DetectTemplateVars reads the variables for the running machine and traffic mode. Facts
+// that cannot be read are 0, which min and max can guard against.
+func DetectTemplateVars(mode TrafficMode) TemplateVars {
+	vars := TemplateVars{PageSize: os.Getpagesize(), Mode: mode}
+	if memKB, err := sysinfo.ReadMemoryKB("/proc/meminfo"); err == nil {
+		vars.MemKB = memKB
+	}
+	if cpus, err := sysinfo.ReadOnlineCPUs(sysinfo.OnlineCPUsPath); err == nil {
+		vars.CPUs = len(cpus)
+	} else {
+		vars.CPUs = runtime.NumCPU()
+	}
+	if name, err := sysinfo.DefaultRouteInterface(sysinfo.ProcNetRoute); err == nil {
+		if speed, err := sysinfo.LinkSpeedMbps(name); err == nil {
+			vars.LinkSpeedMbps = speed
+		}
+	}
+	return vars
+}
+
+// values exposes the variables under the names templates use.
+func (v TemplateVars) values() map[string]exprValue {
+	pages := 0.0
+	if v.PageSize > 0 {
+		pages = float64(v.MemKB) * 1024 / float64(v.PageSize)
+	}
+	return map[string]exprValue{
+		"MEM_KB":          {num: float64(v.MemKB)},
+		"MEM_PAGES":       {num: pages},
+		"CPUS":            {num: float64(v.CPUs)},
+		"PAGE_SIZE":       {num: float64(v.PageSize)},
+		"LINK_SPEED_MBPS": {num: float64(v.LinkSpeedMbps)},
+		"MODE":            {str: string(v.Mode), isStr: true},
+	}
+}
@@ -1,9 +1,6 @@
 package route
 
-import (
-	"strconv"
-	"time"
-)
+import "time"
 
 // WindowConfig defines the TCP window byte sizes used during route optimization.
 type WindowConfig struct {
@@ -11,6 +8,8 @@ type WindowConfig struct {
 	InitCwndBytes       int
 	InitRwndBytes       int
 	LoopbackWindowBytes int
+	// Policy holds per-table overrides loaded from routes.conf.
+	Policy Policy
 }
 
 const (
@@ -18,33 +17,33 @@ const (
 	defaultCmdTimeout = 5 * time.Second
 )
 
+// keepMetric marks an optional metric that tcsss leaves as the route already has it.
+const keepMetric = -1
+
+// loopbackMTU matches the MTU the shaper sets on lo.
+const loopbackMTU = 65520
+
+// params are job settings before they are resolved against a route's egress MTU; windows are
+// kept in bytes so each route gets the segment count of its own MSS.
 type params struct {
-	mtu      int
-	initCwnd int
-	initRwnd int
-	congctl  string
+	// mtu fixes the route MTU; 0 derives it from the egress link.
+	mtu           int
+	initCwndBytes int
+	initRwndBytes int
+	congctl       string
+	rtoMin        int
+	quickAck      int
 }
 
-func newParams(mtu, initCwnd, initRwnd int, congctl string) params {
+func newParams(mtu, initCwndBytes, initRwndBytes int, congctl string) params {
 	return params{
-		mtu:      mtu,
-		initCwnd: initCwnd,
-		initRwnd: initRwnd,
-		congctl:  congctl,
-	}
-}
-
-func (p params) args() []string {
-	result := []string{
-		"mtu", strconv.Itoa(p.mtu),
-		"initcwnd", strconv.Itoa(p.initCwnd),
-		"initrwnd", strconv.Itoa(p.initRwnd),
-		"fastopen_no_cookie", "1",
-	}
-	if p.congctl != "" {
-		result = append(result, "congctl", "lock", p.congctl)
+		mtu:           mtu,
+		initCwndBytes: initCwndBytes,
+		initRwndBytes: initRwndBytes,
+		congctl:       congctl,
+		rtoMin:        keepMetric,
+		quickAck:      keepMetric,
 	}
-	return result
 }
 
 func (cfg WindowConfig) WithDefaults() WindowConfig {
@@ -0,0 +1,65 @@
+package route
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+)
+
+const availableCongctlPath = "/proc/sys/net/ipv4/tcp_available_congestion_control"
+
+// congctlAvailable reports whether the kernel offers a congestion control algorithm, loading
+// its tcp_<algo> module when it is not listed yet. Results are cached for one optimization pass.
+func (opt *Optimizer) congctlAvailable(ctx context.Context, algo string) bool {
+	if algo == "" {
+		return true
+	}
+
+	opt.congctlMu.Lock()
+	defer opt.congctlMu.Unlock()
+	if available, ok := opt.congctlCache[algo]; ok {
+		return available
+	}
+
+	available := listsCongctl(algo)
+	if !available {
+		output, err := opt.runCommand(ctx, "modprobe", "tcp_"+algo)
+		available = err == nil && listsCongctl(algo)
+		if opt.logger != nil {
+			if available {
+				opt.logger.Info("congestion control module loaded", slog.String("congctl", algo))
+			} else {
+				reason := strings.TrimSpace(output)
+				if reason == "" && err != nil {
+					reason = err.Error()
+				}
+				opt.logger.Warn("congestion control unavailable, keeping default",
+					slog.String("congctl", algo),
+					slog.String("reason", reason))
+			}
+		}
+	}
+
+	if opt.congctlCache == nil {
+		opt.congctlCache = make(map[string]bool)
+	}
+	opt.congctlCache[algo] = available
+	return available
+}
+
+func (opt *Optimizer) resetCongctlCache() {
+	opt.congctlMu.Lock()
+	opt.congctlCache = nil
+	opt.congctlMu.Unlock()
+}
+
+func listsCongctl(algo string) bool {
+	data, err := os.ReadFile(availableCongctlPath)
+	if err != nil {
+		// Without the list the kernel is the judge; a bad name fails the route replace.
+		return true
+	}
+	return slices.Contains(strings.Fields(string(data)), algo)
+}
@@ -1,69 +1,42 @@
 package route
 
 import (
-	"context"
 	"fmt"
-	"log/slog"
 	"os"
 	"strings"
 
 	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
 )
 
 func (opt *Optimizer) getPrimaryNIC() (string, error) {
-	if opt.netlink != nil {
-		if nic, err := opt.getPrimaryNICFromNetlink(); err == nil && nic != "" {
-			return nic, nil
-		} else if err != nil && opt.logger != nil {
-			opt.logger.Debug("netlink primary NIC detection failed", slog.String("error", err.Error()))
-		}
+	if opt.netlink == nil {
+		return "", fmt.Errorf("netlink client unavailable")
 	}
-	return opt.getPrimaryNICFromCommand()
+	return opt.getPrimaryNICFromNetlink()
 }
 
-func (opt *Optimizer) getPrimaryNICFromCommand() (string, error) {
-	ctx := context.Background()
-	lines, err := opt.fetchRoutes(ctx, "route", "show")
-	if err != nil {
-		return "", err
-	}
-
-	for _, raw := range lines {
-		line := strings.TrimSpace(raw)
-		if !strings.HasPrefix(line, "default ") {
-			continue
-		}
-		if nic, ok := extractDevice(line); ok && nic != "" {
-			return nic, nil
-		}
-	}
-
-	for _, raw := range lines {
-		line := strings.TrimSpace(raw)
-		if strings.Contains(line, "linkdown") {
-			continue
-		}
-
-		nic, ok := extractDevice(line)
-		if !ok || nic == "lo" {
+// getPrimaryNICFromNetlink prefers the IPv4 default route, then the IPv6 default route, then
+// the first physical device carrying any route.
+func (opt *Optimizer) getPrimaryNICFromNetlink() (string, error) {
+	var routes []netlink.Route
+	var listErr error
+	for _, fam := range []addressFamily{familyIPv4, familyIPv6} {
+		familyRoutes, err := opt.netlink.RouteList(nil, fam.netlink)
+		if err != nil {
+			if listErr == nil {
+				listErr = fmt.Errorf("route list %s: %w", fam.name, err)
+			}
 			continue
 		}
-		if !isVirtualName(nic) {
-			return nic, nil
-		}
+		routes = append(routes, familyRoutes...)
 	}
-
-	return "", fmt.Errorf("no suitable network interface found")
-}
-
-func (opt *Optimizer) getPrimaryNICFromNetlink() (string, error) {
-	routes, err := opt.netlink.RouteList(nil, netlink.FAMILY_V4)
-	if err != nil {
-		return "", fmt.Errorf("route list: %w", err)
+	if len(routes) == 0 && listErr != nil {
+		return "", listErr
 	}
 
 	for _, route := range routes {
-		if route.Dst != nil || route.LinkIndex <= 0 {
+		if !isDefaultRoute(route) || route.LinkIndex <= 0 {
 			continue
 		}
 		attrs, err := safeGetLinkAttrs(opt.netlink, route.LinkIndex)
@@ -113,65 +86,108 @@ func (opt *Optimizer) getCurrentCongestionControl() (string, error) {
 	return "", fmt.Errorf("empty congestion control value")
 }
 
-func shouldOptimizeLocal(line string) bool {
-	if line == "" || !strings.HasPrefix(line, "local ") {
+func shouldOptimizeLocal(route netlink.Route, names map[int]string) bool {
+	return route.Type == unix.RTN_LOCAL && names[route.LinkIndex] != "lo"
+}
+
+func shouldOptimizeLoopback(route netlink.Route, names map[int]string) bool {
+	return route.Type == unix.RTN_LOCAL && names[route.LinkIndex] == "lo"
+}
+
+func shouldOptimizeNIC(route netlink.Route, names map[int]string, uplinks map[string]struct{}) bool {
+	if route.Type != unix.RTN_UNICAST {
 		return false
 	}
-	if strings.Contains(line, "broadcast") {
-		return false
+	for _, index := range routeDevices(route) {
+		if _, ok := uplinks[names[index]]; ok {
+			return true
+		}
 	}
-	if strings.Contains(line, "linkdown") {
-		return false
+	return false
+}
+
+// routeDevices returns the output interfaces of a route, including every multipath nexthop.
+func routeDevices(route netlink.Route) []int {
+	var devices []int
+	if route.LinkIndex > 0 {
+		devices = append(devices, route.LinkIndex)
+	}
+	for _, nh := range route.MultiPath {
+		if nh.LinkIndex > 0 {
+			devices = append(devices, nh.LinkIndex)
+		}
 	}
-	if device, ok := extractDevice(line); ok && device == "lo" {
+	return devices
+}
+
+// isLinkDown reports whether the route, or every one of its nexthops, sits on a link without carrier.
+func isLinkDown(route netlink.Route) bool {
+	if route.Flags&unix.RTNH_F_LINKDOWN != 0 {
+		return true
+	}
+	if len(route.MultiPath) == 0 {
 		return false
 	}
+	for _, nh := range route.MultiPath {
+		if nh.Flags&unix.RTNH_F_LINKDOWN == 0 {
+			return false
+		}
+	}
 	return true
 }
 
-func shouldOptimizeLoopback(line string) bool {
-	if line == "" || !strings.HasPrefix(line, "local ") {
-		return false
+// unsupportedRoute explains why a route cannot be re-installed faithfully, or returns "".
+func unsupportedRoute(route netlink.Route) string {
+	if isLinkDown(route) {
+		// The kernel refuses nexthops on devices without carrier; the route is tuned when the link comes up.
+		return "link down"
 	}
-	device, ok := extractDevice(line)
-	if !ok {
-		return false
+	if route.Encap != nil || route.NewDst != nil || route.Via != nil || route.MPLSDst != nil {
+		return "encapsulated or MPLS route"
+	}
+	for _, nh := range route.MultiPath {
+		if nh.Encap != nil || nh.NewDst != nil || nh.Via != nil {
+			return "encapsulated nexthop"
+		}
 	}
-	return device == "lo"
+	if route.Protocol == unix.RTPROT_RA {
+		// A replacement loses the kernel's RTF_ADDRCONF flag, so later advertisements would add a
+		// second default route instead of refreshing or withdrawing this one.
+		return "router advertisement route"
+	}
+	return ""
 }
 
-func shouldOptimizeNIC(line, nic string) bool {
-	if line == "" || strings.Contains(line, "linkdown") || strings.Contains(line, "congctl") {
-		return false
+// IPv6 NLM_F_REPLACE treats metric 0 as 1024, so a metric 0 route cannot be addressed, and it
+// replaces the first route sharing the destination and metric, whichever device it uses. Such
+// routes are skipped rather than duplicating them or replacing a sibling route. The local
+// table, where every route has metric 0, is not tuned for IPv6 at all.
+func ipv6ReplaceConflict(route netlink.Route, shared map[routeKey]bool) string {
+	if route.Priority == 0 {
+		return "IPv6 metric 0 route cannot be replaced"
 	}
-	device, ok := extractDevice(line)
-	if !ok {
-		return false
+	if shared[keyOf(route, netlink.FAMILY_V6)] {
+		return "IPv6 destination and metric shared by several routes"
 	}
-	return device == nic
+	return ""
 }
 
-func extractDevice(output string) (string, bool) {
-	if output == "" {
-		return "", false
+// sharedIPv6Keys returns the IPv6 route keys that occur more than once in a table.
+func sharedIPv6Keys(fam addressFamily, routes []netlink.Route) map[routeKey]bool {
+	if fam != familyIPv6 {
+		return nil
 	}
-	for _, line := range strings.Split(output, "\n") {
-		fields := strings.Fields(line)
-		if len(fields) == 0 {
-			continue
-		}
-		for i := 0; i < len(fields)-1; i++ {
-			if fields[i] != "dev" {
-				continue
-			}
-			device := fields[i+1]
-			if device == "" {
-				return "", false
-			}
-			return device, true
+	counts := make(map[routeKey]int, len(routes))
+	for _, route := range routes {
+		counts[keyOf(route, fam.netlink)]++
+	}
+	shared := make(map[routeKey]bool)
+	for key, count := range counts {
+		if count > 1 {
+			shared[key] = true
 		}
 	}
-	return "", false
+	return shared
 }
 
 func isVirtualName(name string) bool {
@@ -0,0 +1,58 @@
+package route
+
+import (
+	"os"
+
+	"github.com/vishvananda/netlink"
+)
+
+// ipv6ProcPath exists only when the kernel has IPv6 enabled.
+const ipv6ProcPath = "/proc/net/if_inet6"
+
+// addressFamily carries the per-family differences of route optimization.
+type addressFamily struct {
+	name    string
+	netlink int
+	// minMTU is the smallest route MTU the family allows (RFC 8200 requires 1280 for IPv6).
+	minMTU int
+	// headerBytes is the IP plus TCP header overhead subtracted from the MTU to get the MSS.
+	headerBytes int
+	// localRoutes enables the loopback and local jobs. The kernel installs IPv6 local-table
+	// routes (::1 and the host's own addresses) at metric 0, which NLM_F_REPLACE reads as 1024,
+	// so a replacement would add a duplicate route instead of tuning them.
+	localRoutes bool
+}
+
+var (
+	familyIPv4 = addressFamily{name: "ipv4", netlink: netlink.FAMILY_V4, headerBytes: 40, localRoutes: true}
+	familyIPv6 = addressFamily{name: "ipv6", netlink: netlink.FAMILY_V6, minMTU: 1280, headerBytes: 60}
+)
+
+// families returns the address families present on the host.
+func (opt *Optimizer) families() []addressFamily {
+	if _, err := os.Stat(ipv6ProcPath); err != nil {
+		return []addressFamily{familyIPv4}
+	}
+	return []addressFamily{familyIPv4, familyIPv6}
+}
+
+// label names a route category within the family; IPv4 keeps the historical unprefixed names.
+func (f addressFamily) label(category string) string {
+	if f == familyIPv4 {
+		return category
+	}
+	return f.name + " " + category
+}
+
+// mtu raises a route MTU to the family's minimum.
+func (f addressFamily) mtu(mtu int) int {
+	return max(mtu, f.minMTU)
+}
+
+func isDefaultRoute(route netlink.Route) bool {
+	if route.Dst == nil {
+		return true
+	}
+	ones, bits := route.Dst.Mask.Size()
+	return bits > 0 && ones == 0
+}
@@ -0,0 +1,374 @@
+package route
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// managedLocks are the RTAX_LOCK bits owned by tcsss; other lock bits on a route are preserved.
+const managedLocks = 1 << unix.RTAX_CC_ALGO
+
+// userHZ is the clock_t rate of rta_cacheinfo.rta_expires.
+const userHZ = 100
+
+// rawRouteAttrs are route attributes the netlink library does not parse, read from a raw dump
+// so that replacing a route keeps them.
+type rawRouteAttrs struct {
+	// locks is the complete RTAX_LOCK bitmask.
+	locks uint32
+	// expires is the remaining lifetime in seconds of an IPv6 route with an expiry, 0 when permanent.
+	expires uint32
+	// pref is the IPv6 router preference (RTA_PREF), valid when hasPref is set.
+	pref    uint8
+	hasPref bool
+}
+
+// routeMetrics are the RTA_METRICS values tcsss manages on a route.
+type routeMetrics struct {
+	mtu              int
+	initCwnd         int
+	initRwnd         int
+	congctl          string
+	fastOpenNoCookie int
+	// rtoMin (milliseconds) and quickAck are keepMetric unless a policy sets them.
+	rtoMin   int
+	quickAck int
+	// locks is the RTAX_LOCK bitmask restricted to managedLocks.
+	locks uint32
+}
+
+// metrics converts job parameters into route metrics for a route whose egress MTU is mtu.
+// Windows become segments of the MSS that MTU allows. A congestion control algorithm is
+// always locked so sockets cannot override it, matching `congctl lock`.
+func (p params) metrics(fam addressFamily, mtu int) routeMetrics {
+	if p.mtu > 0 {
+		mtu = p.mtu
+	}
+	mtu = fam.mtu(mtu)
+	mss := mtu - fam.headerBytes
+	m := routeMetrics{
+		mtu:              mtu,
+		initCwnd:         bytesToSegments(p.initCwndBytes, mss),
+		initRwnd:         bytesToSegments(p.initRwndBytes, mss),
+		congctl:          p.congctl,
+		fastOpenNoCookie: 1,
+		rtoMin:           p.rtoMin,
+		quickAck:         p.quickAck,
+	}
+	if p.congctl != "" {
+		m.locks |= 1 << unix.RTAX_CC_ALGO
+	}
+	return m
+}
+
+// currentMetrics reads the managed metrics of a route; the lock bitmask comes from the raw dump
+// because the netlink library only recognises a single locked metric.
+func currentMetrics(route netlink.Route, locks uint32) routeMetrics {
+	return routeMetrics{
+		mtu:              route.MTU,
+		initCwnd:         route.InitCwnd,
+		initRwnd:         route.InitRwnd,
+		congctl:          route.Congctl,
+		fastOpenNoCookie: route.FastOpenNoCookie,
+		rtoMin:           route.RtoMin,
+		quickAck:         route.QuickACK,
+		locks:            locks & managedLocks,
+	}
+}
+
+// diff lists the changes needed to move from m to want, formatted as "name old->new".
+func (m routeMetrics) diff(want routeMetrics) []string {
+	var changes []string
+	intChange := func(name string, have, target int) {
+		if have != target {
+			changes = append(changes, fmt.Sprintf("%s %d->%d", name, have, target))
+		}
+	}
+	intChange("mtu", m.mtu, want.mtu)
+	intChange("initcwnd", m.initCwnd, want.initCwnd)
+	intChange("initrwnd", m.initRwnd, want.initRwnd)
+	if m.congctl != want.congctl {
+		changes = append(changes, fmt.Sprintf("congctl %s->%s", orNone(m.congctl), orNone(want.congctl)))
+	}
+	intChange("fastopen_no_cookie", m.fastOpenNoCookie, want.fastOpenNoCookie)
+	if want.rtoMin != keepMetric {
+		intChange("rto_min", m.rtoMin, want.rtoMin)
+	}
+	if want.quickAck != keepMetric {
+		intChange("quickack", m.quickAck, want.quickAck)
+	}
+	if m.locks != want.locks {
+		changes = append(changes, fmt.Sprintf("lock %s->%s", lockNames(m.locks), lockNames(want.locks)))
+	}
+	return changes
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
+
+func lockNames(locks uint32) string {
+	if locks&(1<<unix.RTAX_CC_ALGO) != 0 {
+		return "congctl"
+	}
+	return "none"
+}
+
+// routeKey identifies a route within its table the way the kernel matches NLM_F_REPLACE requests.
+type routeKey struct {
+	family   int
+	table    int
+	dst      string
+	tos      int
+	priority int
+	kind     int
+}
+
+func keyOf(route netlink.Route, family int) routeKey {
+	return routeKey{
+		family:   family,
+		table:    route.Table,
+		dst:      dstString(route.Dst),
+		tos:      route.Tos,
+		priority: route.Priority,
+		kind:     route.Type,
+	}
+}
+
+func dstString(dst *net.IPNet) string {
+	if dst == nil {
+		return "default"
+	}
+	if ones, _ := dst.Mask.Size(); ones == 0 {
+		return "default"
+	}
+	return dst.String()
+}
+
+// metricsClient reads the attributes the netlink library drops and writes routes with a complete
+// RTA_METRICS set.
+type metricsClient interface {
+	RawAttrs(family int) (map[routeKey]rawRouteAttrs, error)
+	ReplaceRoute(route netlink.Route, family int, metrics routeMetrics, raw rawRouteAttrs) error
+}
+
+type netlinkMetricsClient struct{}
+
+// RawAttrs dumps the routes of a family and returns the RTAX_LOCK bitmask, expiry and router
+// preference of each route carrying one.
+func (netlinkMetricsClient) RawAttrs(family int) (map[routeKey]rawRouteAttrs, error) {
+	req := nl.NewNetlinkRequest(unix.RTM_GETROUTE, unix.NLM_F_DUMP)
+	msg := nl.NewRtMsg()
+	msg.Family = uint8(family)
+	msg.Table = unix.RT_TABLE_UNSPEC
+	req.AddData(msg)
+
+	msgs, err := req.Execute(unix.NETLINK_ROUTE, unix.RTM_NEWROUTE)
+	if err != nil {
+		return nil, fmt.Errorf("dump routes: %w", err)
+	}
+
+	routes := make(map[routeKey]rawRouteAttrs)
+	for _, m := range msgs {
+		rt := nl.DeserializeRtMsg(m)
+		attrs, err := nl.ParseRouteAttr(m[rt.Len():])
+		if err != nil {
+			continue
+		}
+		key := routeKey{family: family, table: int(rt.Table), dst: "default", tos: int(rt.Tos), kind: int(rt.Type)}
+		var raw rawRouteAttrs
+		for _, attr := range attrs {
+			switch attr.Attr.Type {
+			case unix.RTA_TABLE:
+				key.table = int(nl.NativeEndian().Uint32(attr.Value))
+			case unix.RTA_DST:
+				if rt.Dst_len > 0 {
+					bits := 8 * len(attr.Value)
+					key.dst = (&net.IPNet{IP: net.IP(attr.Value), Mask: net.CIDRMask(int(rt.Dst_len), bits)}).String()
+				}
+			case unix.RTA_PRIORITY:
+				key.priority = int(nl.NativeEndian().Uint32(attr.Value))
+			case unix.RTA_METRICS:
+				metrics, err := nl.ParseRouteAttr(attr.Value)
+				if err != nil {
+					continue
+				}
+				for _, metric := range metrics {
+					if metric.Attr.Type == unix.RTAX_LOCK && len(metric.Value) >= 4 {
+						raw.locks = nl.NativeEndian().Uint32(metric.Value)
+					}
+				}
+			case unix.RTA_CACHEINFO:
+				// rta_expires follows rta_clntref and rta_lastuse, in clock_t ticks.
+				if family == netlink.FAMILY_V6 && len(attr.Value) >= 12 {
+					if ticks := int32(nl.NativeEndian().Uint32(attr.Value[8:12])); ticks > 0 {
+						raw.expires = uint32((ticks + userHZ - 1) / userHZ)
+					}
+				}
+			case unix.RTA_PREF:
+				if len(attr.Value) >= 1 {
+					raw.pref, raw.hasPref = attr.Value[0], true
+				}
+			}
+		}
+		if raw != (rawRouteAttrs{}) {
+			routes[key] = raw
+		}
+	}
+	return routes, nil
+}
+
+// ReplaceRoute re-installs a route with NLM_F_REPLACE, keeping its identity, nexthops, expiry,
+// router preference and unmanaged metrics and lock bits while setting the managed metrics.
+func (netlinkMetricsClient) ReplaceRoute(route netlink.Route, family int, want routeMetrics, raw rawRouteAttrs) error {
+	req := nl.NewNetlinkRequest(unix.RTM_NEWROUTE, unix.NLM_F_REPLACE|unix.NLM_F_ACK)
+	msg := nl.NewRtMsg()
+	msg.Family = uint8(family)
+	msg.Table = unix.RT_TABLE_UNSPEC
+	msg.Tos = uint8(route.Tos)
+	msg.Protocol = uint8(route.Protocol)
+	msg.Scope = uint8(route.Scope)
+	msg.Type = uint8(route.Type)
+	msg.Flags = uint32(route.Flags) & unix.RTNH_F_ONLINK
+	req.AddData(msg)
+
+	if route.Dst != nil {
+		if ones, _ := route.Dst.Mask.Size(); ones > 0 {
+			msg.Dst_len = uint8(ones)
+			req.AddData(nl.NewRtAttr(unix.RTA_DST, ipBytes(route.Dst.IP, family)))
+		}
+	}
+	req.AddData(nl.NewRtAttr(unix.RTA_TABLE, nl.Uint32Attr(uint32(route.Table))))
+	if route.Src != nil {
+		req.AddData(nl.NewRtAttr(unix.RTA_PREFSRC, ipBytes(route.Src, family)))
+	}
+	// Always sent: IPv6 assumes metric 1024 when it is absent. IPv6 reads 0 as 1024 as well, so
+	// metric 0 IPv6 routes never get here.
+	req.AddData(nl.NewRtAttr(unix.RTA_PRIORITY, nl.Uint32Attr(uint32(route.Priority))))
+	if route.Realm > 0 {
+		req.AddData(nl.NewRtAttr(unix.RTA_FLOW, nl.Uint32Attr(uint32(route.Realm))))
+	}
+	// Without these an expiring route would become permanent and lose its preference.
+	if raw.expires > 0 {
+		req.AddData(nl.NewRtAttr(unix.RTA_EXPIRES, nl.Uint32Attr(raw.expires)))
+	}
+	if raw.hasPref {
+		req.AddData(nl.NewRtAttr(unix.RTA_PREF, []byte{raw.pref}))
+	}
+
+	if len(route.MultiPath) > 0 {
+		var buf []byte
+		for _, nh := range route.MultiPath {
+			rtnh := &nl.RtNexthop{RtNexthop: unix.RtNexthop{
+				Hops:    uint8(nh.Hops),
+				Ifindex: int32(nh.LinkIndex),
+				Flags:   uint8(nh.Flags) & unix.RTNH_F_ONLINK,
+			}}
+			if nh.Gw != nil {
+				rtnh.Children = []nl.NetlinkRequestData{nl.NewRtAttr(unix.RTA_GATEWAY, ipBytes(nh.Gw, family))}
+			}
+			buf = append(buf, rtnh.Serialize()...)
+		}
+		req.AddData(nl.NewRtAttr(unix.RTA_MULTIPATH, buf))
+	} else {
+		if route.Gw != nil {
+			req.AddData(nl.NewRtAttr(unix.RTA_GATEWAY, ipBytes(route.Gw, family)))
+		}
+		if route.LinkIndex > 0 {
+			req.AddData(nl.NewRtAttr(unix.RTA_OIF, nl.Uint32Attr(uint32(route.LinkIndex))))
+		}
+	}
+
+	req.AddData(encodeMetrics(route, want, raw.locks))
+
+	if _, err := req.Execute(unix.NETLINK_ROUTE, 0); err != nil {
+		return fmt.Errorf("replace route %s: %w", dstString(route.Dst), err)
+	}
+	return nil
+}
+
+// encodeMetrics builds RTA_METRICS from the route's existing unmanaged metrics plus the managed set.
+// The kernel keeps only the last RTAX_LOCK attribute, so all lock bits are sent in one.
+func encodeMetrics(route netlink.Route, want routeMetrics, existingLocks uint32) *nl.RtAttr {
+	attr := nl.NewRtAttr(unix.RTA_METRICS, nil)
+	put := func(metric, value int) {
+		if value > 0 {
+			attr.AddRtAttr(metric, nl.Uint32Attr(uint32(value)))
+		}
+	}
+
+	locks := existingLocks&^managedLocks | want.locks
+	if locks != 0 {
+		attr.AddRtAttr(unix.RTAX_LOCK, nl.Uint32Attr(locks))
+	}
+
+	put(unix.RTAX_MTU, want.mtu)
+	put(unix.RTAX_WINDOW, route.Window)
+	put(unix.RTAX_RTT, route.Rtt)
+	put(unix.RTAX_RTTVAR, route.RttVar)
+	put(unix.RTAX_SSTHRESH, route.Ssthresh)
+	put(unix.RTAX_CWND, route.Cwnd)
+	put(unix.RTAX_ADVMSS, route.AdvMSS)
+	put(unix.RTAX_REORDERING, route.Reordering)
+	put(unix.RTAX_HOPLIMIT, route.Hoplimit)
+	put(unix.RTAX_INITCWND, want.initCwnd)
+	put(unix.RTAX_FEATURES, route.Features)
+	put(unix.RTAX_RTO_MIN, keepOr(route.RtoMin, want.rtoMin))
+	put(unix.RTAX_INITRWND, want.initRwnd)
+	put(unix.RTAX_QUICKACK, keepOr(route.QuickACK, want.quickAck))
+	if want.congctl != "" {
+		attr.AddRtAttr(unix.RTAX_CC_ALGO, nl.ZeroTerminated(want.congctl))
+	}
+	put(unix.RTAX_FASTOPEN_NO_COOKIE, want.fastOpenNoCookie)
+	return attr
+}
+
+// keepOr returns the route's current value when want is keepMetric.
+func keepOr(current, want int) int {
+	if want == keepMetric {
+		return current
+	}
+	return want
+}
+
+func ipBytes(ip net.IP, family int) []byte {
+	if family == netlink.FAMILY_V4 {
+		if v4 := ip.To4(); v4 != nil {
+			return v4
+		}
+	}
+	return ip.To16()
+}
+
+// describeRoute renders a route for logs in a form close to `ip route`.
+func describeRoute(route netlink.Route, names map[int]string) string {
+	parts := []string{dstString(route.Dst)}
+	if route.Gw != nil {
+		parts = append(parts, "via", route.Gw.String())
+	}
+	if name, ok := names[route.LinkIndex]; ok {
+		parts = append(parts, "dev", name)
+	}
+	for _, nh := range route.MultiPath {
+		parts = append(parts, "nexthop")
+		if nh.Gw != nil {
+			parts = append(parts, "via", nh.Gw.String())
+		}
+		if name, ok := names[nh.LinkIndex]; ok {
+			parts = append(parts, "dev", name)
+		}
+	}
+	if route.Priority > 0 {
+		parts = append(parts, "metric", strconv.Itoa(route.Priority))
+	}
+	return strings.Join(parts, " ")
+}
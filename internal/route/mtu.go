@@ -0,0 +1,116 @@
+package route
+
+import (
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// tunnelOverhead is the encapsulation overhead added by tunnel link types, taking the larger
+// outer header where a tunnel may run over IPv4 or IPv6.
+var tunnelOverhead = map[string]int{
+	"wireguard": 80,
+	"gre":       24,
+	"gretap":    38,
+	"ip6gre":    48,
+	"ip6gretap": 62,
+	"ipip":      20,
+	"sit":       20,
+	"ip6tnl":    40,
+	"vxlan":     50,
+	"geneve":    50,
+	"ppp":       8,
+}
+
+// linkMTUs returns the effective MTU of every link. A tunnel bound to a lower device is capped
+// at the lower MTU minus the encapsulation overhead, covering tunnels created with the default
+// MTU of 1500; unbound tunnels such as WireGuard keep the MTU they were given.
+func linkMTUs(links []netlink.Link) map[int]int {
+	byIndex := make(map[int]netlink.Link, len(links))
+	for _, link := range links {
+		if attrs := link.Attrs(); attrs != nil {
+			byIndex[attrs.Index] = link
+		}
+	}
+
+	mtus := make(map[int]int, len(links))
+	for index, link := range byIndex {
+		mtu := link.Attrs().MTU
+		if overhead, ok := tunnelOverhead[link.Type()]; ok {
+			if lower, ok := byIndex[lowerIndex(link)]; ok && lower.Attrs().MTU > overhead {
+				mtu = min(mtu, lower.Attrs().MTU-overhead)
+			}
+		}
+		if mtu > 0 {
+			mtus[index] = mtu
+		}
+	}
+	return mtus
+}
+
+// lowerIndex returns the index of the device carrying a tunnel's encapsulated packets, or 0.
+func lowerIndex(link netlink.Link) int {
+	if vxlan, ok := link.(*netlink.Vxlan); ok && vxlan.VtepDevIndex > 0 {
+		return vxlan.VtepDevIndex
+	}
+	return link.Attrs().ParentIndex
+}
+
+// egressMTU returns the smallest effective MTU among a route's devices. When no device MTU is
+// known the configured MSS decides, as before link MTUs were consulted.
+func (opt *Optimizer) egressMTU(snap routeSnapshot, route netlink.Route) int {
+	mtu := 0
+	for _, index := range routeDevices(route) {
+		if linkMTU, ok := snap.mtus[index]; ok && (mtu == 0 || linkMTU < mtu) {
+			mtu = linkMTU
+		}
+	}
+	if mtu == 0 {
+		mtu = opt.cfg.MSSBytes + familyIPv4.headerBytes
+	}
+	return mtu
+}
+
+// linkState is what a pass saw of a link, to spot the changes that call for re-tuning.
+type linkState struct {
+	mtu     int
+	carrier bool
+}
+
+func stateOf(attrs *netlink.LinkAttrs) linkState {
+	return linkState{mtu: attrs.MTU, carrier: attrs.RawFlags&unix.IFF_LOWER_UP != 0}
+}
+
+// rememberLinks records the link states of a pass for TracksLink.
+func (opt *Optimizer) rememberLinks(links []netlink.Link) {
+	states := make(map[int]linkState, len(links))
+	for _, link := range links {
+		if attrs := link.Attrs(); attrs != nil {
+			states[attrs.Index] = stateOf(attrs)
+		}
+	}
+
+	opt.linksMu.Lock()
+	defer opt.linksMu.Unlock()
+
+	opt.links = states
+}
+
+// TracksLink reports whether a link event changed the MTU of a link seen by the last pass,
+// which changes the route MTU and segment counts of its routes, or brought its carrier up,
+// which makes routes skipped as link down tunable.
+func (opt *Optimizer) TracksLink(update netlink.LinkUpdate) bool {
+	attrs := update.Attrs()
+	if update.Header.Type != unix.RTM_NEWLINK || attrs == nil {
+		return false
+	}
+
+	opt.linksMu.Lock()
+	defer opt.linksMu.Unlock()
+
+	seen, ok := opt.links[attrs.Index]
+	if !ok {
+		return false
+	}
+	now := stateOf(attrs)
+	return seen.mtu != now.mtu || (!seen.carrier && now.carrier)
+}
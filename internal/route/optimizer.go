@@ -1,47 +1,51 @@
 package route
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"log/slog"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
 	terr "tcsss/internal/errors"
 )
 
 // Optimizer handles route table optimization.
 type Optimizer struct {
-	logger               *slog.Logger
-	cfg                  WindowConfig
-	initCwndSegments     int
-	initRwndSegments     int
-	loopbackCwndSegments int
-	loopbackRwndSegments int
-	netlink              NetlinkClient
-	executor             CommandExecutor
-	commandTimeout       time.Duration
+	logger         *slog.Logger
+	cfg            WindowConfig
+	netlink        NetlinkClient
+	metrics        metricsClient
+	executor       CommandExecutor
+	commandTimeout time.Duration
+
+	congctlMu    sync.Mutex
+	congctlCache map[string]bool
+
+	// links holds the link states seen by the last pass, to spot MTU and carrier changes.
+	linksMu sync.Mutex
+	links   map[int]linkState
+
+	uplinksMu sync.Mutex
+	uplinks   []UplinkReport
 }
 
 // NewOptimizer constructs an Optimizer with dependencies.
 func NewOptimizer(logger *slog.Logger, cfg WindowConfig, deps Dependencies) *Optimizer {
 	cfg = cfg.WithDefaults()
 
-	initCwndSegments := bytesToSegments(cfg.InitCwndBytes, cfg.MSSBytes)
-	initRwndSegments := bytesToSegments(cfg.InitRwndBytes, cfg.MSSBytes)
-	loopbackWindowSegments := bytesToSegments(cfg.LoopbackWindowBytes, cfg.MSSBytes)
-
 	opt := &Optimizer{
-		logger:               logger,
-		cfg:                  cfg,
-		initCwndSegments:     initCwndSegments,
-		initRwndSegments:     initRwndSegments,
-		loopbackCwndSegments: loopbackWindowSegments,
-		loopbackRwndSegments: loopbackWindowSegments,
-		netlink:              deps.Netlink,
-		executor:             deps.Executor,
-		commandTimeout:       deps.CommandTimeout,
+		logger:         logger,
+		cfg:            cfg,
+		netlink:        deps.Netlink,
+		metrics:        netlinkMetricsClient{},
+		executor:       deps.Executor,
+		commandTimeout: deps.CommandTimeout,
 	}
 
 	if opt.commandTimeout <= 0 {
@@ -50,48 +54,116 @@ func NewOptimizer(logger *slog.Logger, cfg WindowConfig, deps Dependencies) *Opt
 
 	if opt.logger != nil {
 		opt.logger.Info("Route optimizer initialized",
-			slog.Int("mss_bytes", opt.cfg.MSSBytes),
+			slog.Int("fallback_mss_bytes", opt.cfg.MSSBytes),
 			slog.Int("initcwnd_bytes", opt.cfg.InitCwndBytes),
-			slog.Int("initcwnd_segments", opt.initCwndSegments),
 			slog.Int("initrwnd_bytes", opt.cfg.InitRwndBytes),
-			slog.Int("initrwnd_segments", opt.initRwndSegments),
-			slog.Int("loopback_window_bytes", opt.cfg.LoopbackWindowBytes),
-			slog.Int("loopback_window_segments", opt.loopbackCwndSegments))
+			slog.Int("loopback_window_bytes", opt.cfg.LoopbackWindowBytes))
 	}
 
 	return opt
 }
 
-// Optimize applies route tuning for loopback, local and NIC routes.
+// Optimize applies route tuning for loopback, local and NIC routes of every address family.
 func (opt *Optimizer) Optimize(ctx context.Context) error {
+	return opt.run(ctx, false)
+}
+
+// Reconcile re-runs route tuning after route changes. Routes that already carry the desired
+// metrics are left alone, and summaries are logged at debug level unless a route changed.
+func (opt *Optimizer) Reconcile(ctx context.Context) error {
+	return opt.run(ctx, true)
+}
+
+// Tracks reports whether a route event may need reconciliation: a new or replaced unicast or
+// local route from an allowed protocol.
+func (opt *Optimizer) Tracks(update netlink.RouteUpdate) bool {
+	if update.Type != unix.RTM_NEWROUTE {
+		return false
+	}
+	if update.Route.Type != unix.RTN_UNICAST && update.Route.Type != unix.RTN_LOCAL {
+		return false
+	}
+	return opt.cfg.Policy.allowsProtocol(update.Route.Protocol)
+}
+
+func (opt *Optimizer) run(ctx context.Context, reconcile bool) error {
 	var errs terr.MultiError
 
-	if err := opt.optimizeLoopback(ctx); err != nil {
-		errs.Add(fmt.Errorf("loopback: %w", err))
-		if opt.logger != nil {
-			opt.logger.Warn("Failed to optimize loopback routes", slog.String("error", err.Error()))
+	if opt.netlink == nil {
+		return terr.New(
+			terr.CategoryRecoverable,
+			fmt.Errorf("netlink client unavailable"),
+			terr.ErrorContext{Operation: "optimize_routes"},
+		)
+	}
+
+	opt.resetCongctlCache()
+	reports := newUplinkReports()
+	uplinks := make(map[string]struct{})
+	var snaps []routeSnapshot
+	for _, fam := range opt.families() {
+		snap := opt.snapshot(fam)
+		snap.reconcile = reconcile
+		snap.reports = reports
+		if err := opt.loadNICTables(&snap, uplinks); err != nil {
+			errs.Add(fmt.Errorf("%s: %w", fam.label("nic"), err))
 		}
+		snaps = append(snaps, snap)
 	}
 
-	if err := opt.optimizeLocal(ctx); err != nil {
-		errs.Add(fmt.Errorf("local: %w", err))
-		if opt.logger != nil {
-			opt.logger.Warn("Failed to optimize local routes", slog.String("error", err.Error()))
+	if len(uplinks) == 0 {
+		nic, err := opt.getPrimaryNIC()
+		if err != nil || nic == "" {
+			errs.Add(terr.New(
+				terr.CategoryRecoverable,
+				fmt.Errorf("failed to detect primary NIC: %w", err),
+				terr.ErrorContext{Operation: "detect_primary_nic"},
+			))
+		} else {
+			uplinks[nic] = struct{}{}
 		}
 	}
 
-	if err := opt.optimizeNIC(ctx); err != nil {
-		errs.Add(fmt.Errorf("nic: %w", err))
-		if opt.logger != nil {
-			opt.logger.Warn("Failed to optimize NIC routes", slog.String("error", err.Error()))
+	for _, snap := range snaps {
+		fam := snap.family
+
+		if fam.localRoutes {
+			if err := opt.optimizeLoopback(ctx, snap); err != nil {
+				errs.Add(fmt.Errorf("%s: %w", fam.label("loopback"), err))
+				if opt.logger != nil {
+					opt.logger.Warn("Failed to optimize loopback routes", slog.String("family", fam.name), slog.String("error", err.Error()))
+				}
+			}
+
+			if err := opt.optimizeLocal(ctx, snap); err != nil {
+				errs.Add(fmt.Errorf("%s: %w", fam.label("local"), err))
+				if opt.logger != nil {
+					opt.logger.Warn("Failed to optimize local routes", slog.String("family", fam.name), slog.String("error", err.Error()))
+				}
+			}
+		} else if opt.logger != nil && !reconcile {
+			opt.logger.Debug("loopback and local routes left untouched",
+				slog.String("family", fam.name),
+				slog.String("reason", "metric 0 routes cannot be replaced"))
+		}
+
+		if len(uplinks) == 0 {
+			continue
+		}
+		if err := opt.optimizeNIC(ctx, snap, uplinks); err != nil {
+			errs.Add(fmt.Errorf("%s: %w", fam.label("nic"), err))
+			if opt.logger != nil {
+				opt.logger.Warn("Failed to optimize NIC routes", slog.String("family", fam.name), slog.String("error", err.Error()))
+			}
 		}
 	}
+	opt.publishUplinks(ctx, reports, uplinks, reconcile)
 
 	finalErr := errs.ErrorOrNil()
 	if opt.logger != nil {
 		if finalErr != nil {
 			opt.logger.Warn("Route optimization completed with errors", slog.Int("error_count", errs.Len()))
-		} else {
+		} else if !reconcile {
 			opt.logger.Info("Route optimization completed successfully")
 		}
 	}
@@ -99,93 +171,343 @@ func (opt *Optimizer) Optimize(ctx context.Context) error {
 	return finalErr
 }
 
-func (opt *Optimizer) optimizeLocal(ctx context.Context) error {
+// routeSnapshot holds the per-family state shared by the jobs of one optimization pass.
+type routeSnapshot struct {
+	family addressFamily
+	names  map[int]string
+	mtus   map[int]int
+	raw    map[routeKey]rawRouteAttrs
+	// tables and tableRoutes hold the NIC tables of the family and their routes.
+	tables      []routeTable
+	tableRoutes map[int][]netlink.Route
+	reports     *uplinkReports
+	// reconcile marks an event-driven pass, whose routine summaries are logged at debug level.
+	reconcile bool
+}
+
+// summaryLevel is the log level of a job summary; reconcile passes that changed nothing use debug.
+func (snap routeSnapshot) summaryLevel(changed bool) slog.Level {
+	if snap.reconcile && !changed {
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
+
+func (opt *Optimizer) snapshot(fam addressFamily) routeSnapshot {
+	snap := routeSnapshot{family: fam, names: make(map[int]string)}
+	if links, err := opt.netlink.LinkList(); err == nil {
+		for _, link := range links {
+			if attrs := link.Attrs(); attrs != nil {
+				snap.names[attrs.Index] = attrs.Name
+			}
+		}
+		snap.mtus = linkMTUs(links)
+		opt.rememberLinks(links)
+	}
+	raw, err := opt.metrics.RawAttrs(fam.netlink)
+	if err != nil && opt.logger != nil {
+		opt.logger.Debug("raw route attributes unavailable", slog.String("family", fam.name), slog.String("error", err.Error()))
+	}
+	snap.raw = raw
+	return snap
+}
+
+func (opt *Optimizer) listTable(fam addressFamily, table int) ([]netlink.Route, error) {
+	return opt.netlink.RouteListFiltered(fam.netlink, &netlink.Route{Table: table}, netlink.RT_FILTER_TABLE)
+}
+
+func (opt *Optimizer) optimizeLocal(ctx context.Context, snap routeSnapshot) error {
+	fam := snap.family
 	job := routeJob{
-		category:       "local",
-		routeArgs:      []string{"route", "show", "table", "local"},
+		category:       fam.label("local"),
+		table:          tableLocal,
 		filter:         shouldOptimizeLocal,
-		params:         newParams(1500, opt.initCwndSegments, opt.initRwndSegments, "cubic"),
+		class:          ClassLoopback,
+		params:         newParams(0, opt.cfg.InitCwndBytes, opt.cfg.InitRwndBytes, "cubic"),
+		defaultCongctl: "cubic",
 		fetchOperation: "fetch_local_routes",
 		applyOperation: "optimize_local_routes",
+		commonLogAttrs: []slog.Attr{slog.String("family", fam.name)},
 	}
-	return opt.optimize(ctx, job)
+	return opt.optimize(ctx, snap, job)
 }
 
-func (opt *Optimizer) optimizeLoopback(ctx context.Context) error {
+func (opt *Optimizer) optimizeLoopback(ctx context.Context, snap routeSnapshot) error {
+	fam := snap.family
 	job := routeJob{
-		category:       "loopback",
-		routeArgs:      []string{"route", "show", "table", "local"},
+		category:       fam.label("loopback"),
+		table:          tableLocal,
 		filter:         shouldOptimizeLoopback,
-		params:         newParams(65520, opt.loopbackCwndSegments, opt.loopbackRwndSegments, "cubic"),
+		class:          ClassLoopback,
+		params:         newParams(loopbackMTU, opt.cfg.LoopbackWindowBytes, opt.cfg.LoopbackWindowBytes, "cubic"),
+		defaultCongctl: "cubic",
 		fetchOperation: "fetch_loopback_routes",
 		applyOperation: "optimize_loopback_routes",
+		commonLogAttrs: []slog.Attr{slog.String("family", fam.name)},
 	}
-	return opt.optimize(ctx, job)
+	return opt.optimize(ctx, snap, job)
 }
 
-func (opt *Optimizer) optimizeNIC(ctx context.Context) error {
-	nic, err := opt.getPrimaryNIC()
-	if err != nil || nic == "" {
-		return terr.New(
-			terr.CategoryRecoverable,
-			fmt.Errorf("failed to detect primary NIC: %w", err),
-			terr.ErrorContext{Operation: "detect_primary_nic"},
-		)
+// loadNICTables lists the NIC tables of a family and adds every device holding a default route
+// in one of them, at any metric and including multipath nexthops, to uplinks. Devices whose
+// carrier is down still count, so backup uplinks are reported and tuned once they come up.
+func (opt *Optimizer) loadNICTables(snap *routeSnapshot, uplinks map[string]struct{}) error {
+	var errs terr.MultiError
+	fam := snap.family
+	snap.tables = opt.discoverTables(fam)
+	snap.tableRoutes = make(map[int][]netlink.Route, len(snap.tables))
+	for _, table := range snap.tables {
+		routes, err := opt.listTable(fam, table.id)
+		if err != nil {
+			errs.Add(opt.nicJob(fam, table, nil, "").fetchError(err))
+			continue
+		}
+		snap.tableRoutes[table.id] = routes
+		for _, route := range routes {
+			if !isDefaultRoute(route) || route.Type != unix.RTN_UNICAST {
+				continue
+			}
+			for _, index := range routeDevices(route) {
+				if name := snap.names[index]; name != "" && name != "lo" {
+					uplinks[name] = struct{}{}
+				}
+			}
+		}
 	}
+	return errs.ErrorOrNil()
+}
+
+// optimizeNIC tunes uplink routes in main and every policy-routing or VRF table. Uplinks are
+// shared by both families, so a device with only an IPv6 default route has its IPv4 routes tuned too.
+func (opt *Optimizer) optimizeNIC(ctx context.Context, snap routeSnapshot, uplinks map[string]struct{}) error {
+	var errs terr.MultiError
+	fam := snap.family
 
 	congctl, err := opt.getCurrentCongestionControl()
 	if err != nil {
 		congctl = "cubic"
 	}
 
-	job := routeJob{
-		category:  "nic",
-		routeArgs: []string{"route", "show"},
-		filter: func(line string) bool {
-			return shouldOptimizeNIC(line, nic)
-		},
-		params:         newParams(1500, opt.initCwndSegments, opt.initRwndSegments, congctl),
-		fetchOperation: "fetch_nic_routes",
-		applyOperation: "optimize_nic_routes",
-		commonLogAttrs: []slog.Attr{
-			slog.String("interface", nic),
-			slog.String("congctl", congctl),
+	for _, table := range snap.tables {
+		routes, ok := snap.tableRoutes[table.id]
+		if !ok {
+			continue
+		}
+		job := opt.nicJob(fam, table, uplinks, congctl)
+		if job.skip {
+			if opt.logger != nil {
+				opt.logger.Log(ctx, snap.summaryLevel(false), "route table skipped by policy",
+					slog.String("family", fam.name),
+					slog.String("table", table.name))
+			}
+			continue
+		}
+		if err := opt.optimizeRoutes(ctx, snap, job, routes); err != nil {
+			errs.Add(err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// nicJob builds the NIC job of one table, applying the table's policy on top of the defaults.
+func (opt *Optimizer) nicJob(fam addressFamily, table routeTable, uplinks map[string]struct{}, congctl string) routeJob {
+	params := newParams(0, opt.cfg.InitCwndBytes, opt.cfg.InitRwndBytes, congctl)
+	policy, hasPolicy := opt.cfg.Policy.tablePolicy(table)
+	if hasPolicy {
+		params = params.tune(policy.Tuning)
+	}
+
+	category := fam.label("nic")
+	if !table.isMain() {
+		category += " table " + table.name
+	}
+	devices := make([]string, 0, len(uplinks))
+	for device := range uplinks {
+		devices = append(devices, device)
+	}
+	sort.Strings(devices)
+
+	logAttrs := []slog.Attr{
+		slog.String("family", fam.name),
+		slog.String("table", table.name),
+		slog.String("interfaces", strings.Join(devices, ",")),
+		slog.String("congctl", params.congctl),
+	}
+	if table.vrf != "" {
+		logAttrs = append(logAttrs, slog.String("vrf", table.vrf))
+	}
+
+	return routeJob{
+		category: category,
+		table:    table.id,
+		filter: func(route netlink.Route, names map[int]string) bool {
+			return shouldOptimizeNIC(route, names, uplinks)
 		},
-		commonErrContext: terr.ErrorContext{Interface: nic},
+		uplinks:          uplinks,
+		params:           params,
+		defaultCongctl:   congctl,
+		skip:             hasPolicy && policy.Skip,
+		fetchOperation:   "fetch_nic_routes",
+		applyOperation:   "optimize_nic_routes",
+		commonLogAttrs:   logAttrs,
+		commonErrContext: terr.ErrorContext{Interface: strings.Join(devices, ",")},
 	}
-	return opt.optimize(ctx, job)
 }
 
-func (opt *Optimizer) optimize(ctx context.Context, job routeJob) error {
-	lines, err := opt.fetchRoutes(ctx, job.routeArgs...)
+// tune layers a table or class policy entry onto job parameters.
+func (p params) tune(tuning Tuning) params {
+	if tuning.Congctl != "" {
+		p.congctl = tuning.Congctl
+	}
+	if tuning.InitCwndBytes > 0 {
+		p.initCwndBytes = tuning.InitCwndBytes
+	}
+	if tuning.InitRwndBytes > 0 {
+		p.initRwndBytes = tuning.InitRwndBytes
+	}
+	if tuning.RTOMinMs > 0 {
+		p.rtoMin = tuning.RTOMinMs
+	}
+	if tuning.HasQuickAck {
+		p.quickAck = tuning.QuickAck
+	}
+	return p
+}
+
+// routeParams applies the class policy of a route on top of the job parameters. An unavailable
+// congestion control falls back to the job's, then to the job default.
+func (opt *Optimizer) routeParams(ctx context.Context, job routeJob, class string) params {
+	p := job.params
+	if entry, ok := opt.cfg.Policy.classPolicy(class); ok {
+		p = p.tune(entry.Tuning)
+	}
+	for _, algo := range []string{p.congctl, job.params.congctl} {
+		if opt.congctlAvailable(ctx, algo) {
+			p.congctl = algo
+			return p
+		}
+	}
+	p.congctl = job.defaultCongctl
+	return p
+}
+
+func (opt *Optimizer) optimize(ctx context.Context, snap routeSnapshot, job routeJob) error {
+	routes, err := opt.listTable(snap.family, job.table)
 	if err != nil {
 		return job.fetchError(err)
 	}
+	return opt.optimizeRoutes(ctx, snap, job, routes)
+}
 
-	filtered := opt.filterRoutes(lines, job.filter)
+// optimizeRoutes sets the job's metrics on every matching route, reporting each change and
+// leaving routes that already carry the desired metrics untouched.
+func (opt *Optimizer) optimizeRoutes(ctx context.Context, snap routeSnapshot, job routeJob, routes []netlink.Route) error {
+	var selected []netlink.Route
+	foreign := 0
+	for _, route := range routes {
+		if !job.filter(route, snap.names) {
+			continue
+		}
+		// Routes owned by routing daemons are reinstalled by them; tuning them would only fight.
+		if !opt.cfg.Policy.allowsProtocol(route.Protocol) {
+			foreign++
+			continue
+		}
+		selected = append(selected, route)
+	}
 
 	if opt.logger != nil {
 		attrs := appendAttrs(job.commonLogAttrs,
-			slog.Int("total_routes", len(filtered)),
+			slog.Int("total_routes", len(selected)),
+			slog.Int("other_protocol_routes", foreign),
 		)
-		opt.logger.Info(fmt.Sprintf("%s routes optimization started", job.category), terr.AttrsToArgs(attrs)...)
+		opt.logger.Log(ctx, snap.summaryLevel(false), fmt.Sprintf("%s routes optimization started", job.category), terr.AttrsToArgs(attrs)...)
 	}
 
 	start := time.Now()
-	optimized, skipped, applyErr := opt.applyRoutes(ctx, filtered, job.params.args(), job.category)
+	shared := sharedIPv6Keys(snap.family, routes)
+	var optimized, unchanged, skipped, failed int
+	var firstErr error
+
+	for _, route := range selected {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		description := describeRoute(route, snap.names)
+		reason := unsupportedRoute(route)
+		if reason == "" && snap.family == familyIPv6 {
+			reason = ipv6ReplaceConflict(route, shared)
+		}
+		if reason == "" && snap.family == familyIPv6 && snap.raw == nil {
+			// Replacing without the dump would drop the route's expiry and preference.
+			reason = "route expiry and preference unavailable"
+		}
+		if reason != "" {
+			skipped++
+			job.record(snap, route, outcomeSkipped, routeMetrics{})
+			if opt.logger != nil {
+				opt.logger.Debug("route optimization skipped",
+					slog.String("category", job.category),
+					slog.String("route", description),
+					slog.String("reason", reason))
+			}
+			continue
+		}
+
+		class := job.class
+		if class == "" {
+			class = opt.cfg.Policy.classify(route.Dst)
+		}
+		want := opt.routeParams(ctx, job, class).metrics(snap.family, opt.egressMTU(snap, route))
+		raw := snap.raw[keyOf(route, snap.family.netlink)]
+		changes := currentMetrics(route, raw.locks).diff(want)
+		if len(changes) == 0 {
+			unchanged++
+			job.record(snap, route, outcomeUnchanged, want)
+			continue
+		}
+
+		if err := opt.metrics.ReplaceRoute(route, snap.family.netlink, want, raw); err != nil {
+			failed++
+			job.record(snap, route, outcomeFailed, want)
+			if firstErr == nil {
+				firstErr = err
+			}
+			if opt.logger != nil {
+				opt.logger.Debug("route optimization failed",
+					slog.String("category", job.category),
+					slog.String("route", description),
+					slog.String("error", err.Error()))
+			}
+			continue
+		}
+		optimized++
+		job.record(snap, route, outcomeOptimized, want)
+		if opt.logger != nil {
+			opt.logger.Info("route tuned",
+				slog.String("category", job.category),
+				slog.String("route", description),
+				slog.String("class", class),
+				slog.Int("table", route.Table),
+				slog.String("changes", strings.Join(changes, ", ")))
+		}
+	}
 
 	if opt.logger != nil {
 		attrs := appendAttrs(job.commonLogAttrs,
 			slog.Int("optimized", optimized),
+			slog.Int("unchanged", unchanged),
 			slog.Int("skipped", skipped),
-			slog.Int("total", len(filtered)),
+			slog.Int("failed", failed),
+			slog.Int("total", len(selected)),
 			slog.Duration("duration", time.Since(start)),
 		)
-		opt.logger.Info(fmt.Sprintf("%s routes optimization completed", job.category), terr.AttrsToArgs(attrs)...)
+		level := snap.summaryLevel(optimized > 0 || failed > 0)
+		opt.logger.Log(ctx, level, fmt.Sprintf("%s routes optimization completed", job.category), terr.AttrsToArgs(attrs)...)
 	}
 
-	if applyErr != nil {
-		return job.applyError(applyErr)
+	if firstErr != nil {
+		return job.applyError(firstErr)
 	}
 	return nil
 }
@@ -209,13 +531,19 @@ func cloneAttrs(attrs []slog.Attr) []slog.Attr {
 	return out
 }
 
-type routeFilter func(string) bool
+type routeFilter func(route netlink.Route, names map[int]string) bool
 
+// routeJob describes one route category to tune. An empty class classifies each route by
+// its destination; uplinks is set for NIC jobs, whose routes are reported per uplink.
 type routeJob struct {
 	category         string
-	routeArgs        []string
+	table            int
 	filter           routeFilter
+	class            string
+	uplinks          map[string]struct{}
 	params           params
+	defaultCongctl   string
+	skip             bool
 	fetchOperation   string
 	applyOperation   string
 	commonLogAttrs   []slog.Attr
@@ -240,61 +568,6 @@ func (job routeJob) applyError(err error) error {
 	)
 }
 
-func (opt *Optimizer) cleanRouteLine(line string) string {
-	tokens := strings.Fields(line)
-	if len(tokens) == 0 {
-		return ""
-	}
-
-	result := make([]string, 0, len(tokens))
-	skipNext := false
-
-	for i := 0; i < len(tokens); i++ {
-		if skipNext {
-			skipNext = false
-			continue
-		}
-
-		token := tokens[i]
-		switch token {
-		case "mtu", "initcwnd", "initrwnd", "fastopen_no_cookie":
-			if i+1 < len(tokens) {
-				skipNext = true
-			}
-			continue
-		case "congctl":
-			if i+2 < len(tokens) && tokens[i+1] == "lock" {
-				i += 2
-				continue
-			}
-		}
-		result = append(result, token)
-	}
-
-	return strings.Join(result, " ")
-}
-
-func (opt *Optimizer) applyRouteChange(ctx context.Context, routeLine string, params ...string) error {
-	tokens := strings.Fields(routeLine)
-	if len(tokens) == 0 {
-		return fmt.Errorf("empty route line")
-	}
-	args := append([]string{"route", "change"}, tokens...)
-	args = append(args, params...)
-	if _, err := opt.runIPCommand(ctx, args...); err != nil {
-		return fmt.Errorf("ip %s: %w", strings.Join(args, " "), err)
-	}
-	return nil
-}
-
-func (opt *Optimizer) runIPCommand(ctx context.Context, args ...string) (string, error) {
-	ctx, cancel := opt.commandContext(ctx)
-	defer cancel()
-
-	executor := ensureExecutor(opt.executor)
-	return executor.Run(ctx, "ip", args)
-}
-
 func (opt *Optimizer) runCommand(ctx context.Context, name string, args ...string) (string, error) {
 	ctx, cancel := opt.commandContext(ctx)
 	defer cancel()
@@ -303,23 +576,6 @@ func (opt *Optimizer) runCommand(ctx context.Context, name string, args ...strin
 	return executor.Run(ctx, name, args)
 }
 
-func (opt *Optimizer) fetchRoutes(ctx context.Context, args ...string) ([]string, error) {
-	output, err := opt.runIPCommand(ctx, args...)
-	if err != nil {
-		return nil, err
-	}
-
-	var lines []string
-	scanner := bufio.NewScanner(strings.NewReader(output))
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-	return lines, nil
-}
-
 func (opt *Optimizer) commandContext(parent context.Context) (context.Context, context.CancelFunc) {
 	if parent == nil {
 		parent = context.Background()
@@ -330,55 +586,3 @@ func (opt *Optimizer) commandContext(parent context.Context) (context.Context, c
 	}
 	return context.WithTimeout(parent, timeout)
 }
-
-func (opt *Optimizer) filterRoutes(lines []string, predicate routeFilter) []string {
-	result := make([]string, 0, len(lines))
-	for _, raw := range lines {
-		line := strings.TrimSpace(raw)
-		if predicate(line) {
-			result = append(result, line)
-		}
-	}
-	return result
-}
-
-func (opt *Optimizer) applyRoutes(ctx context.Context, routes []string, params []string, category string) (int, int, error) {
-	if len(routes) == 0 {
-		return 0, 0, nil
-	}
-
-	optimized := 0
-	failures := 0
-	var firstErr error
-
-	for _, route := range routes {
-		if route == "" {
-			continue
-		}
-		routeLine := opt.cleanRouteLine(route)
-		if routeLine == "" {
-			continue
-		}
-		if err := opt.applyRouteChange(ctx, routeLine, params...); err != nil {
-			if firstErr == nil {
-				firstErr = err
-			}
-			if opt.logger != nil {
-				opt.logger.Debug("route optimization skipped",
-					slog.String("category", category),
-					slog.String("route", routeLine),
-					slog.String("error", err.Error()))
-			}
-			failures++
-			continue
-		}
-		optimized++
-		if opt.logger != nil {
-			opt.logger.Debug("route optimization applied",
-				slog.String("category", category),
-				slog.String("route", routeLine))
-		}
-	}
-
-	return optimized, failures, firstErr
-}
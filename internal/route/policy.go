@@ -0,0 +1,356 @@
+package route
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	tmpl "tcsss/internal/config"
+)
+
+// PolicyFile is the optional route tuning policy read from the configuration directory.
+const PolicyFile = "routes.conf"
+
+// Destination classes a ClassPolicy can target.
+const (
+	ClassLoopback   = "loopback"
+	ClassPrivate    = "private"
+	ClassDatacenter = "datacenter"
+	ClassInternet   = "internet"
+)
+
+var routeClasses = []string{ClassLoopback, ClassPrivate, ClassDatacenter, ClassInternet}
+
+// privateNetworks are the RFC 1918 and RFC 4193 (ULA) ranges of the private class.
+var privateNetworks = mustParseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7")
+
+// defaultProtocols are the route origins tuned when routes.conf sets no protocols entry; routes
+// installed by routing daemons are left alone so tcsss does not fight them. Router advertisement
+// routes are listed so uplink reports count them as skipped (see unsupportedRoute) rather than
+// hiding SLAAC default routes among foreign ones.
+var defaultProtocols = []int{unix.RTPROT_KERNEL, unix.RTPROT_BOOT, unix.RTPROT_STATIC, unix.RTPROT_DHCP, unix.RTPROT_RA}
+
+// protocolNames maps the route protocol names of rt_protos(5) to their numbers.
+var protocolNames = map[string]int{
+	"unspec":     unix.RTPROT_UNSPEC,
+	"redirect":   unix.RTPROT_REDIRECT,
+	"kernel":     unix.RTPROT_KERNEL,
+	"boot":       unix.RTPROT_BOOT,
+	"static":     unix.RTPROT_STATIC,
+	"ra":         unix.RTPROT_RA,
+	"zebra":      unix.RTPROT_ZEBRA,
+	"bird":       unix.RTPROT_BIRD,
+	"dhcp":       unix.RTPROT_DHCP,
+	"keepalived": unix.RTPROT_KEEPALIVED,
+	"babel":      unix.RTPROT_BABEL,
+	"bgp":        unix.RTPROT_BGP,
+	"isis":       unix.RTPROT_ISIS,
+	"ospf":       unix.RTPROT_OSPF,
+	"rip":        unix.RTPROT_RIP,
+	"eigrp":      unix.RTPROT_EIGRP,
+}
+
+// Tuning holds the route settings a policy entry can override; zero values keep the default.
+type Tuning struct {
+	Congctl       string
+	InitCwndBytes int
+	InitRwndBytes int
+	RTOMinMs      int
+	// QuickAck is applied only when HasQuickAck is set, since 0 is a meaningful value.
+	QuickAck    int
+	HasQuickAck bool
+}
+
+// TablePolicy overrides NIC route tuning for one routing table.
+type TablePolicy struct {
+	// Table is the table name or number as written in the policy file.
+	Table string
+	Skip  bool
+	Tuning
+}
+
+// ClassPolicy overrides route tuning for one destination class.
+type ClassPolicy struct {
+	Class string
+	Tuning
+}
+
+// Policy holds the route tuning overrides loaded from routes.conf.
+type Policy struct {
+	Tables  []TablePolicy
+	Classes []ClassPolicy
+	// Datacenter lists the networks of the datacenter class.
+	Datacenter []*net.IPNet
+	// Protocols lists the route protocols that are tuned; nil selects defaultProtocols.
+	Protocols []int
+}
+
+// LoadPolicy reads routes.conf from the template directory. A missing file yields an empty policy.
+func LoadPolicy(templateDir string) (Policy, error) {
+	data, err := os.ReadFile(filepath.Join(templateDir, PolicyFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Policy{}, nil
+		}
+		return Policy{}, fmt.Errorf("read %s: %w", PolicyFile, err)
+	}
+	return ParsePolicy(string(data))
+}
+
+// ParsePolicy parses policy entries of the form
+//
+//	table.<name|id> = skip
+//	table.<name|id> = <settings>
+//	class.<loopback|private|datacenter|internet> = <settings>
+//	datacenter = <cidr> [<cidr>...]
+//	protocols = <name|number> [<name|number>...]
+//
+// where settings are [congctl=<algo>] [initcwnd=<bytes>] [initrwnd=<bytes>] [rto_min=<time>]
+// [quickack=<0|1>], bytes accept a k or m suffix and times default to milliseconds.
+func ParsePolicy(content string) (Policy, error) {
+	var policy Policy
+	for lineNo, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return Policy{}, fmt.Errorf("line %d: expected <key> = <value>", lineNo+1)
+		}
+		key = strings.TrimSpace(key)
+		value = tmpl.StripInlineComment(value)
+
+		if err := policy.parseEntry(key, value); err != nil {
+			return Policy{}, fmt.Errorf("line %d: %w", lineNo+1, err)
+		}
+	}
+	return policy, nil
+}
+
+func (p *Policy) parseEntry(key, value string) error {
+	if key == "datacenter" {
+		networks, err := parseCIDRs(strings.Fields(strings.ReplaceAll(value, ",", " ")))
+		if err != nil {
+			return fmt.Errorf("datacenter: %w", err)
+		}
+		p.Datacenter = append(p.Datacenter, networks...)
+		return nil
+	}
+
+	if key == "protocols" {
+		protocols, err := parseProtocols(strings.Fields(strings.ReplaceAll(value, ",", " ")))
+		if err != nil {
+			return fmt.Errorf("protocols: %w", err)
+		}
+		p.Protocols = protocols
+		return nil
+	}
+
+	if class, ok := strings.CutPrefix(key, "class."); ok {
+		if !slices.Contains(routeClasses, class) {
+			return fmt.Errorf("unknown class %q", class)
+		}
+		tuning, err := parseTuning(value)
+		if err != nil {
+			return fmt.Errorf("class %s: %w", class, err)
+		}
+		p.Classes = append(p.Classes, ClassPolicy{Class: class, Tuning: tuning})
+		return nil
+	}
+
+	table, ok := strings.CutPrefix(key, "table.")
+	if !ok || table == "" {
+		return fmt.Errorf("unknown key %q", key)
+	}
+	entry := TablePolicy{Table: table}
+	if value == "skip" {
+		entry.Skip = true
+	} else {
+		tuning, err := parseTuning(value)
+		if err != nil {
+			return fmt.Errorf("table %s: %w", table, err)
+		}
+		entry.Tuning = tuning
+	}
+	p.Tables = append(p.Tables, entry)
+	return nil
+}
+
+func parseTuning(value string) (Tuning, error) {
+	var tuning Tuning
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return Tuning{}, fmt.Errorf("empty policy")
+	}
+	for _, field := range fields {
+		k, v, ok := strings.Cut(field, "=")
+		if !ok || v == "" {
+			return Tuning{}, fmt.Errorf("invalid setting %q", field)
+		}
+		var err error
+		switch k {
+		case "congctl":
+			tuning.Congctl = v
+		case "initcwnd":
+			tuning.InitCwndBytes, err = parseBytes(v)
+		case "initrwnd":
+			tuning.InitRwndBytes, err = parseBytes(v)
+		case "rto_min":
+			tuning.RTOMinMs, err = parseMillis(v)
+		case "quickack":
+			switch v {
+			case "0", "1":
+				tuning.QuickAck, tuning.HasQuickAck = int(v[0]-'0'), true
+			default:
+				err = fmt.Errorf("quickack must be 0 or 1, got %q", v)
+			}
+		default:
+			err = fmt.Errorf("unknown setting %q", k)
+		}
+		if err != nil {
+			return Tuning{}, err
+		}
+	}
+	return tuning, nil
+}
+
+func parseBytes(value string) (int, error) {
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(value, "k"):
+		multiplier, value = 1024, strings.TrimSuffix(value, "k")
+	case strings.HasSuffix(value, "m"):
+		multiplier, value = 1024*1024, strings.TrimSuffix(value, "m")
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid byte size %q", value)
+	}
+	return n * multiplier, nil
+}
+
+// parseMillis accepts a Go duration such as 200ms or a bare number of milliseconds.
+func parseMillis(value string) (int, error) {
+	if n, err := strconv.Atoi(value); err == nil && n > 0 {
+		return n, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < time.Millisecond {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return int(d / time.Millisecond), nil
+}
+
+func parseCIDRs(values []string) ([]*net.IPNet, error) {
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no networks given")
+	}
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", value)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func parseProtocols(values []string) ([]int, error) {
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no protocols given")
+	}
+	protocols := make([]int, 0, len(values))
+	for _, value := range values {
+		proto, ok := protocolNames[value]
+		if !ok {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > 255 {
+				return nil, fmt.Errorf("unknown protocol %q", value)
+			}
+			proto = n
+		}
+		protocols = append(protocols, proto)
+	}
+	return protocols, nil
+}
+
+func mustParseCIDRs(values ...string) []*net.IPNet {
+	networks, err := parseCIDRs(values)
+	if err != nil {
+		panic(err)
+	}
+	return networks
+}
+
+// tablePolicy returns the policy entry for a table, matching any of its names or its number.
+func (p Policy) tablePolicy(table routeTable) (TablePolicy, bool) {
+	for _, entry := range p.Tables {
+		if slices.Contains(table.aliases, entry.Table) || entry.Table == strconv.Itoa(table.id) {
+			return entry, true
+		}
+	}
+	return TablePolicy{}, false
+}
+
+// classPolicy returns the policy entry for a destination class.
+func (p Policy) classPolicy(class string) (ClassPolicy, bool) {
+	for _, entry := range p.Classes {
+		if entry.Class == class {
+			return entry, true
+		}
+	}
+	return ClassPolicy{}, false
+}
+
+// classify maps a route destination to its class. Datacenter networks take precedence over
+// the private ranges they usually overlap; default routes and public prefixes are internet.
+func (p Policy) classify(dst *net.IPNet) string {
+	if dst == nil {
+		return ClassInternet
+	}
+	if ones, _ := dst.Mask.Size(); ones == 0 {
+		return ClassInternet
+	}
+	if dst.IP.IsLoopback() {
+		return ClassLoopback
+	}
+	if within(dst, p.Datacenter) {
+		return ClassDatacenter
+	}
+	if within(dst, privateNetworks) {
+		return ClassPrivate
+	}
+	return ClassInternet
+}
+
+// within reports whether dst lies entirely inside one of the networks.
+func within(dst *net.IPNet, networks []*net.IPNet) bool {
+	dstOnes, dstBits := dst.Mask.Size()
+	for _, network := range networks {
+		ones, bits := network.Mask.Size()
+		if bits == dstBits && ones <= dstOnes && network.Contains(dst.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsProtocol reports whether routes installed by a protocol are tuned.
+func (p Policy) allowsProtocol(proto netlink.RouteProtocol) bool {
+	protocols := p.Protocols
+	if protocols == nil {
+		protocols = defaultProtocols
+	}
+	return slices.Contains(protocols, int(proto))
+}
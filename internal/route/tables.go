@@ -0,0 +1,140 @@
+package route
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+
+	tmpl "tcsss/internal/config"
+)
+
+// Reserved routing table numbers (see rt_tables(5)).
+const (
+	tableDefault = 253
+	tableMain    = 254
+	tableLocal   = 255
+)
+
+// rtTablesPaths lists the iproute2 files that name routing tables, in lookup order.
+var rtTablesPaths = []string{
+	"/etc/iproute2/rt_tables",
+	"/usr/share/iproute2/rt_tables",
+	"/usr/lib/iproute2/rt_tables",
+}
+
+// routeTable identifies a routing table selected for NIC route tuning.
+type routeTable struct {
+	id   int
+	name string
+	// aliases lists every name of the table in file order; name is the first.
+	aliases []string
+	// vrf names the VRF device bound to the table, if any.
+	vrf string
+}
+
+func (t routeTable) isMain() bool {
+	return t.id == tableMain
+}
+
+// discoverTables returns main plus every table referenced by a routing rule and every VRF table.
+// The local table is excluded because the loopback and local jobs handle it.
+func (opt *Optimizer) discoverTables(fam addressFamily) []routeTable {
+	names := readTableNames()
+	found := map[int]routeTable{tableMain: {id: tableMain, name: "main", aliases: names[tableMain]}}
+
+	add := func(id int, vrf string) {
+		if id <= 0 || id == tableLocal {
+			return
+		}
+		table, ok := found[id]
+		if !ok {
+			table = routeTable{id: id, name: tableName(id, names), aliases: names[id]}
+		}
+		if vrf != "" {
+			table.vrf = vrf
+		}
+		found[id] = table
+	}
+
+	if rules, err := opt.netlink.RuleList(fam.netlink); err != nil {
+		if opt.logger != nil {
+			opt.logger.Debug("rule listing failed, tuning main table only",
+				slog.String("family", fam.name),
+				slog.String("error", err.Error()))
+		}
+	} else {
+		for _, rule := range rules {
+			add(rule.Table, "")
+		}
+	}
+
+	if links, err := opt.netlink.LinkList(); err == nil {
+		for _, link := range links {
+			if vrf, ok := link.(*netlink.Vrf); ok && vrf.Attrs() != nil {
+				add(int(vrf.Table), vrf.Attrs().Name)
+			}
+		}
+	}
+
+	tables := make([]routeTable, 0, len(found))
+	for _, table := range found {
+		tables = append(tables, table)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].isMain() != tables[j].isMain() {
+			return tables[i].isMain()
+		}
+		return tables[i].id < tables[j].id
+	})
+	return tables
+}
+
+// tableName returns the first name of a table in file order, or its number when it has none.
+func tableName(id int, names map[int][]string) string {
+	if aliases := names[id]; len(aliases) > 0 {
+		return aliases[0]
+	}
+	return strconv.Itoa(id)
+}
+
+// readTableNames loads the names of each table from rt_tables and rt_tables.d in file order,
+// seeded with the reserved tables. A name already given to a table is not reassigned.
+func readTableNames() map[int][]string {
+	names := map[int][]string{tableDefault: {"default"}, tableMain: {"main"}, tableLocal: {"local"}}
+	seen := map[string]bool{"default": true, "main": true, "local": true}
+
+	var paths []string
+	for _, base := range rtTablesPaths {
+		paths = append(paths, base)
+		if extra, err := filepath.Glob(base + ".d/*.conf"); err == nil {
+			paths = append(paths, extra...)
+		}
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = tmpl.StripInlineComment(line)
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			id, err := strconv.Atoi(fields[0])
+			if err != nil {
+				continue
+			}
+			if !seen[fields[1]] {
+				seen[fields[1]] = true
+				names[id] = append(names[id], fields[1])
+			}
+		}
+	}
+	return names
+}
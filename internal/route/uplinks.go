@@ -0,0 +1,159 @@
+package route
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+// UplinkReport summarises the NIC routes of one uplink after an optimization pass. MTU and
+// window values list every distinct setting, since class and table policies may differ per route.
+type UplinkReport struct {
+	Name      string   `json:"name"`
+	Families  []string `json:"families,omitempty"`
+	Routes    int      `json:"routes"`
+	Optimized int      `json:"optimized"`
+	Unchanged int      `json:"unchanged"`
+	Skipped   int      `json:"skipped"`
+	Failed    int      `json:"failed"`
+	MTU       []int    `json:"mtu,omitempty"`
+	Congctl   []string `json:"congctl,omitempty"`
+	InitCwnd  []int    `json:"initcwnd,omitempty"`
+	InitRwnd  []int    `json:"initrwnd,omitempty"`
+}
+
+type routeOutcome int
+
+const (
+	outcomeOptimized routeOutcome = iota
+	outcomeUnchanged
+	outcomeSkipped
+	outcomeFailed
+)
+
+// uplinkReports collects the per-uplink reports of one pass across both families.
+type uplinkReports struct {
+	byName map[string]*UplinkReport
+}
+
+func newUplinkReports() *uplinkReports {
+	return &uplinkReports{byName: make(map[string]*UplinkReport)}
+}
+
+func (r *uplinkReports) get(name string) *UplinkReport {
+	report, ok := r.byName[name]
+	if !ok {
+		report = &UplinkReport{Name: name}
+		r.byName[name] = report
+	}
+	return report
+}
+
+// record adds a NIC route to the report of every uplink it leaves through. Multipath routes
+// count once for each uplink among their nexthops.
+func (job routeJob) record(snap routeSnapshot, route netlink.Route, outcome routeOutcome, want routeMetrics) {
+	if job.uplinks == nil || snap.reports == nil {
+		return
+	}
+	seen := make(map[string]bool)
+	for _, index := range routeDevices(route) {
+		name := snap.names[index]
+		if _, ok := job.uplinks[name]; !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		report := snap.reports.get(name)
+		report.Routes++
+		if !slices.Contains(report.Families, snap.family.name) {
+			report.Families = append(report.Families, snap.family.name)
+		}
+		switch outcome {
+		case outcomeOptimized:
+			report.Optimized++
+		case outcomeUnchanged:
+			report.Unchanged++
+		case outcomeSkipped:
+			report.Skipped++
+			continue
+		case outcomeFailed:
+			report.Failed++
+		}
+		report.MTU = addDistinct(report.MTU, want.mtu)
+		report.InitCwnd = addDistinct(report.InitCwnd, want.initCwnd)
+		report.InitRwnd = addDistinct(report.InitRwnd, want.initRwnd)
+		if want.congctl != "" && !slices.Contains(report.Congctl, want.congctl) {
+			report.Congctl = append(report.Congctl, want.congctl)
+			sort.Strings(report.Congctl)
+		}
+	}
+}
+
+func addDistinct(values []int, value int) []int {
+	if value <= 0 || slices.Contains(values, value) {
+		return values
+	}
+	values = append(values, value)
+	slices.Sort(values)
+	return values
+}
+
+// publishUplinks logs one summary per uplink and keeps the reports for Uplinks.
+func (opt *Optimizer) publishUplinks(ctx context.Context, reports *uplinkReports, uplinks map[string]struct{}, reconcile bool) {
+	for name := range uplinks {
+		reports.get(name)
+	}
+	list := make([]UplinkReport, 0, len(reports.byName))
+	for _, report := range reports.byName {
+		list = append(list, *report)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	opt.uplinksMu.Lock()
+	opt.uplinks = list
+	opt.uplinksMu.Unlock()
+
+	if opt.logger == nil {
+		return
+	}
+	for _, report := range list {
+		level := slog.LevelInfo
+		if reconcile && report.Optimized == 0 && report.Failed == 0 {
+			level = slog.LevelDebug
+		}
+		opt.logger.Log(ctx, level, "uplink routes summary",
+			slog.String("interface", report.Name),
+			slog.String("families", strings.Join(report.Families, ",")),
+			slog.Int("routes", report.Routes),
+			slog.Int("optimized", report.Optimized),
+			slog.Int("unchanged", report.Unchanged),
+			slog.Int("skipped", report.Skipped),
+			slog.Int("failed", report.Failed),
+			slog.String("mtu", JoinInts(report.MTU)),
+			slog.String("congctl", strings.Join(report.Congctl, ",")),
+			slog.String("initcwnd", JoinInts(report.InitCwnd)),
+			slog.String("initrwnd", JoinInts(report.InitRwnd)))
+	}
+}
+
+// Uplinks returns the per-uplink reports of the last optimization pass.
+func (opt *Optimizer) Uplinks() []UplinkReport {
+	opt.uplinksMu.Lock()
+	defer opt.uplinksMu.Unlock()
+
+	return slices.Clone(opt.uplinks)
+}
+
+// JoinInts renders report values as a comma-separated list.
+func JoinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = strconv.Itoa(value)
+	}
+	return strings.Join(parts, ",")
+}
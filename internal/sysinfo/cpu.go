@@ -0,0 +1,95 @@
+package sysinfo
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OnlineCPUsPath is the sysfs list of CPUs currently online.
+const OnlineCPUsPath = "/sys/devices/system/cpu/online"
+
+// ReadOnlineCPUs parses a kernel CPU list file (for example "0-3,6") and returns the sorted CPU IDs.
+func ReadOnlineCPUs(path string) ([]int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	cpus, err := ParseCPUList(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("no online CPUs listed in %s", path)
+	}
+	return cpus, nil
+}
+
+// ParseCPUList converts the kernel list format ("0-3,6,8-9") into sorted CPU IDs.
+func ParseCPUList(list string) ([]int, error) {
+	seen := make(map[int]struct{})
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		bounds := strings.SplitN(part, "-", 2)
+		start, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu %q: %w", bounds[0], err)
+		}
+		end := start
+		if len(bounds) == 2 {
+			end, err = strconv.Atoi(bounds[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid cpu %q: %w", bounds[1], err)
+			}
+		}
+		if start < 0 || end < start {
+			return nil, fmt.Errorf("invalid cpu range %q", part)
+		}
+
+		for cpu := start; cpu <= end; cpu++ {
+			seen[cpu] = struct{}{}
+		}
+	}
+
+	cpus := make([]int, 0, len(seen))
+	for cpu := range seen {
+		cpus = append(cpus, cpu)
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
+// FormatCPUList renders sorted CPU IDs back into the compact kernel list format.
+func FormatCPUList(cpus []int) string {
+	if len(cpus) == 0 {
+		return ""
+	}
+
+	var parts []string
+	start, prev := cpus[0], cpus[0]
+	flush := func() {
+		if start == prev {
+			parts = append(parts, strconv.Itoa(start))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", start, prev))
+		}
+	}
+	for _, cpu := range cpus[1:] {
+		if cpu == prev+1 {
+			prev = cpu
+			continue
+		}
+		flush()
+		start, prev = cpu, cpu
+	}
+	flush()
+
+	return strings.Join(parts, ",")
+}
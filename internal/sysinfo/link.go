@@ -0,0 +1,78 @@
+package sysinfo
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ProcNetRoute is the IPv4 routing table exposed by procfs.
+const ProcNetRoute = "/proc/net/route"
+
+// DefaultRouteInterface returns the device of the IPv4 default route with the lowest metric.
+func DefaultRouteInterface(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", path, err)
+	}
+	defer file.Close()
+
+	best, bestMetric := "", -1
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		metric, err := strconv.Atoi(fields[6])
+		if err != nil {
+			continue
+		}
+		if bestMetric < 0 || metric < bestMetric {
+			best, bestMetric = fields[0], metric
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("read %s: %w", path, err)
+	}
+	if best == "" {
+		return "", fmt.Errorf("no IPv4 default route in %s", path)
+	}
+	return best, nil
+}
+
+// LinkSpeedMbps returns the negotiated speed of a link from sysfs. Bridges, bonds and VLANs
+// without a speed of their own report the fastest hardware port beneath them. Virtual links
+// and links without carrier report no speed and return an error.
+func LinkSpeedMbps(name string) (int, error) {
+	if speed, err := readLinkSpeed(name); err == nil {
+		return speed, nil
+	}
+	best := 0
+	for _, nic := range hardwareNICs(name) {
+		if speed, err := readLinkSpeed(nic); err == nil {
+			best = max(best, speed)
+		}
+	}
+	if best == 0 {
+		return 0, fmt.Errorf("no link speed for %s", name)
+	}
+	return best, nil
+}
+
+func readLinkSpeed(name string) (int, error) {
+	path := filepath.Join("/sys/class/net", name, "speed")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("read %s: %w", path, err)
+	}
+	speed, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || speed <= 0 {
+		return 0, fmt.Errorf("no link speed for %s", name)
+	}
+	return speed, nil
+}
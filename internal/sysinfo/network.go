@@ -0,0 +1,328 @@
+package sysinfo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TCP socket tables exposed by procfs.
+const (
+	ProcNetTCP  = "/proc/net/tcp"
+	ProcNetTCP6 = "/proc/net/tcp6"
+)
+
+const (
+	tcpStateEstablished = 0x01
+	tcpStateListen      = 0x0A
+
+	procNetIPv6Route = "/proc/net/ipv6_route"
+	procNetConntrack = "/proc/net/nf_conntrack"
+
+	// conntrackScanLimit bounds how many conntrack entries are inspected for NAT translations.
+	conntrackScanLimit = 4096
+)
+
+// TCPSocketSummary counts TCP sockets by the role they reveal about the host.
+// Sockets whose peer or bind address is loopback are ignored.
+type TCPSocketSummary struct {
+	// Listening counts listeners reachable from outside the host.
+	Listening int
+	// AcceptQueue sums connections waiting in those listeners' accept queues.
+	AcceptQueue int
+	// Inbound counts established connections on a local listening port.
+	Inbound int
+	// Outbound counts established connections initiated by this host.
+	Outbound int
+}
+
+type tcpSocket struct {
+	localIP    net.IP
+	localPort  uint16
+	remoteIP   net.IP
+	state      uint64
+	rxQueueLen int
+}
+
+// ReadTCPSockets summarises the given /proc/net/tcp style tables. Missing tables are skipped,
+// so hosts without IPv6 still produce a summary.
+func ReadTCPSockets(paths ...string) (TCPSocketSummary, error) {
+	var sockets []tcpSocket
+	read := 0
+	for _, path := range paths {
+		parsed, err := readTCPTable(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return TCPSocketSummary{}, err
+		}
+		read++
+		sockets = append(sockets, parsed...)
+	}
+	if read == 0 {
+		return TCPSocketSummary{}, fmt.Errorf("no TCP socket tables readable in %s", strings.Join(paths, ", "))
+	}
+
+	var summary TCPSocketSummary
+	listenPorts := make(map[uint16]struct{})
+	for _, sock := range sockets {
+		if sock.state != tcpStateListen {
+			continue
+		}
+		listenPorts[sock.localPort] = struct{}{}
+		if sock.localIP.IsLoopback() {
+			continue
+		}
+		summary.Listening++
+		summary.AcceptQueue += sock.rxQueueLen
+	}
+
+	for _, sock := range sockets {
+		if sock.state != tcpStateEstablished || sock.remoteIP.IsLoopback() || sock.localIP.IsLoopback() {
+			continue
+		}
+		if _, ok := listenPorts[sock.localPort]; ok {
+			summary.Inbound++
+		} else {
+			summary.Outbound++
+		}
+	}
+	return summary, nil
+}
+
+func readTCPTable(path string) ([]tcpSocket, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var sockets []tcpSocket
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		localIP, localPort, err := parseProcAddress(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		remoteIP, _, err := parseProcAddress(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		state, err := strconv.ParseUint(fields[3], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: invalid state %q", path, fields[3])
+		}
+		sock := tcpSocket{localIP: localIP, localPort: localPort, remoteIP: remoteIP, state: state}
+		if _, rx, ok := strings.Cut(fields[4], ":"); ok {
+			if n, err := strconv.ParseUint(rx, 16, 32); err == nil {
+				sock.rxQueueLen = int(n)
+			}
+		}
+		sockets = append(sockets, sock)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return sockets, nil
+}
+
+// parseProcAddress decodes "0100007F:0277". The address is stored as 32-bit words in host
+// byte order, so each word is converted back to network order.
+func parseProcAddress(value string) (net.IP, uint16, error) {
+	addrHex, portHex, ok := strings.Cut(value, ":")
+	if !ok || (len(addrHex) != 8 && len(addrHex) != 32) {
+		return nil, 0, fmt.Errorf("invalid socket address %q", value)
+	}
+	raw, err := hex.DecodeString(addrHex)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid socket address %q", value)
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		binary.NativeEndian.PutUint32(ip[i:], binary.BigEndian.Uint32(raw[i:]))
+	}
+	port, err := strconv.ParseUint(portHex, 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid socket port %q", value)
+	}
+	return ip, uint16(port), nil
+}
+
+// ForwardingEnabled reports whether IPv4 or IPv6 packet forwarding is switched on.
+func ForwardingEnabled() bool {
+	for _, path := range []string{"/proc/sys/net/ipv4/ip_forward", "/proc/sys/net/ipv6/conf/all/forwarding"} {
+		if data, err := os.ReadFile(path); err == nil && strings.TrimSpace(string(data)) == "1" {
+			return true
+		}
+	}
+	return false
+}
+
+// containerLinkPrefixes name the bridges and pod links of container and VM runtimes. Their
+// runtimes masquerade them on any host with forwarding enabled, so their source NAT says
+// nothing about the host being a gateway. Plain "br" is left out: it is also a LAN bridge.
+var containerLinkPrefixes = []string{
+	"docker", "br-", "veth", "cni", "cbr", "cali", "flannel", "cilium", "weave", "kube-",
+	"podman", "lxcbr", "lxdbr", "virbr",
+}
+
+// NATEvidence describes the address translation found by DetectNAT.
+type NATEvidence struct {
+	// Gateway describes source NAT of traffic from other machines, empty when none was seen.
+	Gateway string
+	// IgnoredLinks lists the container links whose translated traffic was not counted.
+	IgnoredLinks []string
+}
+
+// containerRoute is a route to a subnet behind a container link.
+type containerRoute struct {
+	subnet *net.IPNet
+	link   string
+}
+
+// DetectNAT looks for conntrack entries showing that the host source-NATs traffic from other
+// machines. Destination-only translations (published ports) and traffic from subnets routed
+// to container links are ignored, so Docker and Kubernetes hosts are not taken for gateways.
+func DetectNAT() NATEvidence {
+	routes := append(containerRoutes(ProcNetRoute, parseIPv4Route),
+		containerRoutes(procNetIPv6Route, parseIPv6Route)...)
+	return sourceNAT(procNetConntrack, routes, localAddresses())
+}
+
+func sourceNAT(path string, routes []containerRoute, local map[string]bool) NATEvidence {
+	var evidence NATEvidence
+	file, err := os.Open(path)
+	if err != nil {
+		return evidence
+	}
+	defer file.Close()
+
+	ignored := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for i := 0; i < conntrackScanLimit && scanner.Scan(); i++ {
+		var src, dst []string
+		for _, field := range strings.Fields(scanner.Text()) {
+			if v, ok := strings.CutPrefix(field, "src="); ok {
+				src = append(src, v)
+			} else if v, ok := strings.CutPrefix(field, "dst="); ok {
+				dst = append(dst, v)
+			}
+		}
+		// Source NAT rewrites the reply's destination; published ports only its source.
+		if len(src) < 2 || len(dst) < 2 || src[0] == dst[1] {
+			continue
+		}
+		origin := net.ParseIP(src[0])
+		if origin == nil || local[origin.String()] {
+			continue
+		}
+		if link := containerLink(routes, origin); link != "" {
+			ignored[link] = true
+			continue
+		}
+		evidence.Gateway = "source NAT of forwarded traffic from " + origin.String()
+		break
+	}
+
+	for link := range ignored {
+		evidence.IgnoredLinks = append(evidence.IgnoredLinks, link)
+	}
+	sort.Strings(evidence.IgnoredLinks)
+	return evidence
+}
+
+func containerLink(routes []containerRoute, ip net.IP) string {
+	for _, route := range routes {
+		if route.subnet.Contains(ip) {
+			return route.link
+		}
+	}
+	return ""
+}
+
+// containerRoutes returns the routes of a procfs routing table that lead to container links.
+func containerRoutes(path string, parse func([]string) (string, *net.IPNet, bool)) []containerRoute {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var routes []containerRoute
+	for _, line := range strings.Split(string(data), "\n") {
+		link, subnet, ok := parse(strings.Fields(line))
+		if !ok || !isContainerLink(link) {
+			continue
+		}
+		routes = append(routes, containerRoute{subnet: subnet, link: link})
+	}
+	return routes
+}
+
+func isContainerLink(name string) bool {
+	for _, prefix := range containerLinkPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIPv4Route parses a /proc/net/route line: Iface Destination Gateway Flags RefCnt Use
+// Metric Mask, with addresses in host byte order.
+func parseIPv4Route(fields []string) (string, *net.IPNet, bool) {
+	if len(fields) < 8 {
+		return "", nil, false
+	}
+	dst, _, err := parseProcAddress(fields[1] + ":0")
+	if err != nil {
+		return "", nil, false
+	}
+	mask, _, err := parseProcAddress(fields[7] + ":0")
+	if err != nil {
+		return "", nil, false
+	}
+	return fields[0], &net.IPNet{IP: dst, Mask: net.IPMask(mask)}, true
+}
+
+// parseIPv6Route parses a /proc/net/ipv6_route line, which starts with the destination in
+// network byte order and its prefix length in hex, and ends with the device.
+func parseIPv6Route(fields []string) (string, *net.IPNet, bool) {
+	if len(fields) < 10 {
+		return "", nil, false
+	}
+	raw, err := hex.DecodeString(fields[0])
+	if err != nil || len(raw) != net.IPv6len {
+		return "", nil, false
+	}
+	ones, err := strconv.ParseUint(fields[1], 16, 8)
+	if err != nil || ones > 128 {
+		return "", nil, false
+	}
+	return fields[9], &net.IPNet{IP: net.IP(raw), Mask: net.CIDRMask(int(ones), 128)}, true
+}
+
+// localAddresses returns the host's own addresses; their translations are not forwarding.
+func localAddresses() map[string]bool {
+	local := make(map[string]bool)
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return local
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			local[ipNet.IP.String()] = true
+		}
+	}
+	return local
+}
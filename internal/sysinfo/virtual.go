@@ -0,0 +1,173 @@
+package sysinfo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// virtualVendorIDs maps PCI vendor IDs to virtualization platforms.
+	// These IDs are read from /sys/class/net/{iface}/device/vendor.
+	virtualVendorIDs = map[string]struct{}{
+		"0x1414": {}, // Microsoft Hyper-V
+		"0x15ad": {}, // VMware
+		"0x1af4": {}, // Red Hat (VirtIO)
+		"0x1d0f": {}, // Amazon Web Services (AWS)
+		"0x1ae0": {}, // Google Cloud Platform (GCP)
+		"0x1ec1": {}, // Alibaba Cloud
+		"0x5853": {}, // XenSource (Xen hypervisor)
+	}
+
+	// virtualDriverModules identifies virtual NIC kernel drivers.
+	// These are read from /sys/class/net/{iface}/device/driver/module.
+	virtualDriverModules = map[string]struct{}{
+		"ena":        {}, // AWS Elastic Network Adapter
+		"gve":        {}, // Google Virtual Ethernet (GCP)
+		"hv_netvsc":  {}, // Hyper-V Network Virtual Service Client
+		"netvsc":     {}, // Legacy Hyper-V driver
+		"virtio_net": {}, // VirtIO network driver (KVM/QEMU)
+		"virtio_pci": {}, // VirtIO PCI transport
+		"vmxnet3":    {}, // VMware vmxnet3 paravirtualized NIC
+	}
+)
+
+// VirtualNIC reports whether a network device is backed by virtual hardware, judged by its
+// sysfs path, driver module and PCI vendor.
+func VirtualNIC(name string) bool {
+	sysfsPath := filepath.Join("/sys/class/net", name)
+
+	if resolved, err := filepath.EvalSymlinks(sysfsPath); err == nil {
+		if isSysfsVirtualPath(resolved) {
+			return true
+		}
+	}
+
+	if driver := interfaceDriverModule(sysfsPath); driver != "" {
+		if _, ok := virtualDriverModules[normalizeIdentifier(driver)]; ok {
+			return true
+		}
+	}
+
+	if vendor := interfaceVendor(sysfsPath); vendor != "" {
+		if _, ok := virtualVendorIDs[normalizeIdentifier(vendor)]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Virtualized reports whether the host runs under a hypervisor: the CPU advertises the
+// hypervisor flag (x86), or the hardware behind the default route is a virtual NIC. Bridges,
+// bonds and VLANs are followed to their ports; software devices with no hardware behind them,
+// such as tunnels, say nothing either way.
+func Virtualized() bool {
+	if data, err := os.ReadFile("/proc/cpuinfo"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			key, value, ok := strings.Cut(line, ":")
+			if ok && strings.TrimSpace(key) == "flags" {
+				for _, flag := range strings.Fields(value) {
+					if flag == "hypervisor" {
+						return true
+					}
+				}
+				break
+			}
+		}
+	}
+	if name, err := DefaultRouteInterface(ProcNetRoute); err == nil {
+		for _, nic := range hardwareNICs(name) {
+			if VirtualNIC(nic) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hardwareNICs returns the devices backed by hardware beneath a network device, walking the
+// lower_* links of bridges, bonds and VLANs down to their ports.
+func hardwareNICs(name string) []string {
+	var nics []string
+	seen := make(map[string]bool)
+	var walk func(dev string)
+	walk = func(dev string) {
+		if seen[dev] {
+			return
+		}
+		seen[dev] = true
+		sysfsPath := filepath.Join("/sys/class/net", dev)
+		if lowers, _ := filepath.Glob(filepath.Join(sysfsPath, "lower_*")); len(lowers) > 0 {
+			for _, lower := range lowers {
+				walk(strings.TrimPrefix(filepath.Base(lower), "lower_"))
+			}
+			return
+		}
+		if _, err := os.Stat(filepath.Join(sysfsPath, "device")); err == nil {
+			nics = append(nics, dev)
+		}
+	}
+	walk(name)
+	return nics
+}
+
+// isSysfsVirtualPath checks if the resolved sysfs path indicates a virtual device.
+func isSysfsVirtualPath(resolvedPath string) bool {
+	lower := strings.ToLower(resolvedPath)
+
+	// Standard virtual device path
+	if strings.Contains(lower, "/sys/devices/virtual/") {
+		return true
+	}
+
+	// VirtIO devices (check path component, not substring)
+	pathSegments := strings.Split(lower, "/")
+	for _, segment := range pathSegments {
+		if segment == "virtio" || strings.HasPrefix(segment, "virtio") {
+			return true
+		}
+		if segment == "vmbus" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// interfaceDriverModule extracts the kernel driver module name.
+func interfaceDriverModule(sysfsPath string) string {
+	// Standard modular driver: /sys/class/net/{iface}/device/driver/module
+	if module := readLinkBase(filepath.Join(sysfsPath, "device/driver/module")); module != "" {
+		return module
+	}
+	// Built-in driver: /sys/class/net/{iface}/device/driver
+	return readLinkBase(filepath.Join(sysfsPath, "device/driver"))
+}
+
+// interfaceVendor reads the PCI vendor ID from sysfs.
+func interfaceVendor(sysfsPath string) string {
+	data, err := os.ReadFile(filepath.Join(sysfsPath, "device/vendor"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readLinkBase returns the basename of a symlink target.
+func readLinkBase(path string) string {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+// normalizeIdentifier canonicalizes vendor IDs and driver names.
+func normalizeIdentifier(value string) string {
+	value = strings.TrimSpace(value)
+	value = strings.ToLower(value)
+	// Handle both underscore and hyphen delimiters (some systems report hv-netvsc)
+	value = strings.ReplaceAll(value, "-", "_")
+	return value
+}
@@ -0,0 +1,306 @@
+package syslimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	tmpl "tcsss/internal/config"
+)
+
+const (
+	serviceDropInDir  = "/etc/systemd/system"
+	serviceDropInName = "90-tcsss.conf"
+
+	// managedMarker starts every drop-in tcsss writes, so stale ones can be told apart.
+	managedMarker = "# Managed by tcsss"
+)
+
+// limitScope names who a scoped rlimit rule applies to.
+type limitScope string
+
+const (
+	scopeUser    limitScope = "user"
+	scopeGroup   limitScope = "group"
+	scopeService limitScope = "service"
+)
+
+// limitRule is a scoped template entry, rlimit.<scope>.<name>.<resource> = soft[:hard].
+type limitRule struct {
+	scope    limitScope
+	name     string
+	resource string
+	soft     string
+	hard     string
+}
+
+var (
+	accountName = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.$-]*$`)
+	unitName    = regexp.MustCompile(`^[A-Za-z0-9:_.\\@-]+$`)
+)
+
+// unitSections maps the unit types that accept Limit*= settings to their section.
+var unitSections = map[string]string{
+	".service": "Service",
+	".socket":  "Socket",
+	".mount":   "Mount",
+	".swap":    "Swap",
+}
+
+// systemdLimitNames maps rlimit resource names to the suffix of systemd's Limit*= keys.
+var systemdLimitNames = map[string]string{
+	"nofile":     "NOFILE",
+	"nproc":      "NPROC",
+	"memlock":    "MEMLOCK",
+	"stack":      "STACK",
+	"core":       "CORE",
+	"cpu":        "CPU",
+	"as":         "AS",
+	"data":       "DATA",
+	"fsize":      "FSIZE",
+	"msgqueue":   "MSGQUEUE",
+	"sigpending": "SIGPENDING",
+	"locks":      "LOCKS",
+}
+
+// limitOrder is the order resources are written in, for readability.
+var limitOrder = []string{"nofile", "nproc", "memlock", "stack", "core", "cpu", "as", "data", "fsize", "msgqueue", "sigpending", "locks"}
+
+// splitSoftHard splits "soft:hard"; a single value sets both. The soft limit may not exceed
+// the hard one.
+func splitSoftHard(value string) (string, string, error) {
+	soft, hard, found := strings.Cut(value, ":")
+	soft = strings.TrimSpace(soft)
+	hard = strings.TrimSpace(hard)
+	if !found {
+		hard = soft
+	}
+	softValue, err := parseRlimitValue(soft)
+	if err != nil {
+		return "", "", err
+	}
+	hardValue, err := parseRlimitValue(hard)
+	if err != nil {
+		return "", "", err
+	}
+	if softValue > hardValue {
+		return "", "", fmt.Errorf("soft limit %s exceeds hard limit %s", soft, hard)
+	}
+	return soft, hard, nil
+}
+
+// defaultSoftHard splits a "*" entry, keeping values it cannot parse for both limits as
+// earlier releases did.
+func defaultSoftHard(value string) (string, string) {
+	soft, hard, err := splitSoftHard(value)
+	if err != nil {
+		return value, value
+	}
+	return soft, hard
+}
+
+// parseLimitRule parses the key of a scoped entry without its rlimit. prefix. The scope ends at
+// the first dot and the resource starts after the last one, so unit names may contain dots.
+func parseLimitRule(key, value string) (limitRule, error) {
+	scope, rest, _ := strings.Cut(key, ".")
+	dot := strings.LastIndex(rest, ".")
+	if dot <= 0 {
+		return limitRule{}, fmt.Errorf("expected %s.<name>.<resource>", scope)
+	}
+	rule := limitRule{scope: limitScope(scope), name: rest[:dot], resource: rest[dot+1:]}
+
+	if _, ok := resourceNameToRlimit[rule.resource]; !ok {
+		return limitRule{}, fmt.Errorf("unknown resource %q", rule.resource)
+	}
+	switch rule.scope {
+	case scopeUser, scopeGroup:
+		if !accountName.MatchString(rule.name) {
+			return limitRule{}, fmt.Errorf("invalid %s name %q", rule.scope, rule.name)
+		}
+	case scopeService:
+		if !unitName.MatchString(rule.name) {
+			return limitRule{}, fmt.Errorf("invalid unit name %q", rule.name)
+		}
+		if unitSection(rule.name) == "" {
+			rule.name += ".service"
+		}
+	default:
+		return limitRule{}, fmt.Errorf("unknown scope %q, expected user, group or service", scope)
+	}
+
+	soft, hard, err := splitSoftHard(value)
+	if err != nil {
+		return limitRule{}, err
+	}
+	rule.soft, rule.hard = soft, hard
+	return rule, nil
+}
+
+func unitSection(name string) string {
+	return unitSections[filepath.Ext(name)]
+}
+
+// domain is the limits.conf domain of a user or group rule.
+func (r limitRule) domain() string {
+	if r.scope == scopeGroup {
+		return "@" + r.name
+	}
+	return r.name
+}
+
+// extractRules moves the scoped entries out of the rlimit map, leaving the "*" defaults, and
+// returns them ordered by scope, name and resource.
+func (lca *LimitsConfApplier) extractRules() ([]limitRule, error) {
+	var rules []limitRule
+	for key, value := range lca.rlimits {
+		if !strings.Contains(key, ".") {
+			continue
+		}
+		rule, err := parseLimitRule(key, value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: rlimit.%s: %v", tmpl.ErrInvalidTemplate, key, err)
+		}
+		rules = append(rules, rule)
+		delete(lca.rlimits, key)
+	}
+
+	rank := make(map[string]int, len(limitOrder))
+	for i, res := range limitOrder {
+		rank[res] = i
+	}
+	scopeRank := map[limitScope]int{scopeUser: 0, scopeGroup: 1, scopeService: 2}
+	sort.Slice(rules, func(i, j int) bool {
+		a, b := rules[i], rules[j]
+		if a.scope != b.scope {
+			return scopeRank[a.scope] < scopeRank[b.scope]
+		}
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		return rank[a.resource] < rank[b.resource]
+	})
+	return rules, nil
+}
+
+// systemdLimitValue converts a template value to systemd's units: stack is given in KB and
+// systemd says "infinity" rather than "unlimited".
+func systemdLimitValue(resource, value string) string {
+	if value == "unlimited" {
+		return "infinity"
+	}
+	if resource == "stack" {
+		if v, err := strconv.ParseUint(value, 10, 64); err == nil {
+			return strconv.FormatUint(v*1024, 10)
+		}
+	}
+	return value
+}
+
+// generateServiceDropIns renders one drop-in per unit with service rules, keyed by path.
+func generateServiceDropIns(rules []limitRule) map[string]string {
+	units := make(map[string]*strings.Builder)
+	for _, rule := range rules {
+		if rule.scope != scopeService {
+			continue
+		}
+		sb, ok := units[rule.name]
+		if !ok {
+			sb = &strings.Builder{}
+			fmt.Fprintf(sb, "%s - resource limits for %s\n", managedMarker, rule.name)
+			sb.WriteString("# Reload: systemctl daemon-reload, then restart the unit\n\n")
+			fmt.Fprintf(sb, "[%s]\n", unitSection(rule.name))
+			units[rule.name] = sb
+		}
+		soft := systemdLimitValue(rule.resource, rule.soft)
+		hard := systemdLimitValue(rule.resource, rule.hard)
+		if soft == hard {
+			fmt.Fprintf(sb, "Limit%s=%s\n", systemdLimitNames[rule.resource], soft)
+		} else {
+			fmt.Fprintf(sb, "Limit%s=%s:%s\n", systemdLimitNames[rule.resource], soft, hard)
+		}
+	}
+
+	dropIns := make(map[string]string, len(units))
+	for unit, sb := range units {
+		dropIns[serviceDropInPath(unit)] = sb.String()
+	}
+	return dropIns
+}
+
+func serviceDropInPath(unit string) string {
+	return filepath.Join(serviceDropInDir, unit+".d", serviceDropInName)
+}
+
+// applyServiceLimits writes the unit drop-ins, removes those of units no longer in the
+// templates and reloads systemd when any changed. Units keep their limits until restarted.
+func (lca *LimitsConfApplier) applyServiceLimits(ctx context.Context, rules []limitRule, reexecuted bool) {
+	dropIns := generateServiceDropIns(rules)
+	var changed []string
+
+	paths := make([]string, 0, len(dropIns))
+	for path := range dropIns {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		content := dropIns[path]
+		if existing, err := os.ReadFile(path); err == nil && string(existing) == content {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			lca.logger.Warn("mkdir failed",
+				slog.String("dir", filepath.Dir(path)),
+				slog.String("error", err.Error()))
+			continue
+		}
+		if err := writeFileWithSync(path, []byte(content), dropInPerm); err != nil {
+			lca.logger.Warn("write failed",
+				slog.String("file", path),
+				slog.String("error", err.Error()))
+			continue
+		}
+		lca.logger.Info("config written", slog.String("file", path))
+		changed = append(changed, unitOfDropIn(path))
+	}
+
+	existing, _ := filepath.Glob(filepath.Join(serviceDropInDir, "*.d", serviceDropInName))
+	for _, path := range existing {
+		if _, ok := dropIns[path]; ok {
+			continue
+		}
+		if !lca.removeManaged(path) {
+			continue
+		}
+		// Leave the directory if it holds other drop-ins.
+		_ = os.Remove(filepath.Dir(path))
+		changed = append(changed, unitOfDropIn(path))
+	}
+
+	if len(changed) == 0 {
+		return
+	}
+	// daemon-reexec already reloaded unit files.
+	if !reexecuted {
+		cmd := exec.CommandContext(ctx, "systemctl", "daemon-reload")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			lca.logger.Warn("systemd reload failed, manual reload required",
+				slog.String("error", fmt.Sprintf("%v (output: %s)", err, strings.TrimSpace(string(output)))),
+				slog.String("action", "run 'systemctl daemon-reload' manually"))
+			return
+		}
+	}
+	lca.logger.Info("service limits updated, restart units to apply",
+		slog.String("units", strings.Join(changed, " ")))
+}
+
+func unitOfDropIn(path string) string {
+	return strings.TrimSuffix(filepath.Base(filepath.Dir(path)), ".d")
+}
@@ -2,31 +2,45 @@ package syslimit
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strconv"
+	"slices"
 	"strings"
 
 	tmpl "tcsss/internal/config"
 )
 
+const (
+	limitsDropInPath  = "/etc/security/limits.d/90-tcsss.conf"
+	systemDropInPath  = "/etc/systemd/system.conf.d/90-tcsss.conf"
+	userDropInPath    = "/etc/systemd/user.conf.d/90-tcsss.conf"
+	legacyLimitsPath  = "/etc/security/limits.conf"
+	legacySystemdPath = "/etc/systemd/system.conf"
+
+	// generatedMarker starts the header older releases wrote over limits.conf and system.conf.
+	generatedMarker = "# Generated by tcsss"
+)
+
 // LimitsConfApplier manages system-wide resource limits configuration.
-// Writes configuration files that affect NEW sessions/processes.
+// Writes drop-ins that affect NEW sessions/processes, leaving the main files to the distro.
 type LimitsConfApplier struct {
 	logger      *slog.Logger
 	rlimits     map[string]string // rlimit values from templates
 	templateDir string
+	mode        tmpl.TrafficMode
 }
 
 // NewLimitsConfApplier creates a new instance.
-func NewLimitsConfApplier(logger *slog.Logger, templateDir string) *LimitsConfApplier {
+func NewLimitsConfApplier(logger *slog.Logger, templateDir string, mode tmpl.TrafficMode) *LimitsConfApplier {
 	return &LimitsConfApplier{
 		logger:      logger,
 		rlimits:     make(map[string]string),
 		templateDir: templateDir,
+		mode:        mode,
 	}
 }
 
@@ -35,6 +49,8 @@ type fileConfig struct {
 	path    string
 	content string
 	perm    os.FileMode
+	// reexec marks files systemd only reads on daemon-reexec.
+	reexec bool
 }
 
 // parseRlimitsFromTemplate extracts rlimit.* entries from template content.
@@ -63,23 +79,21 @@ func (lca *LimitsConfApplier) loadRlimitsFromTemplates(templates tmpl.TemplateSe
 	lca.parseRlimitsFromTemplate(templates.Specific) // Overrides common
 }
 
-// generateLimitsConf generates /etc/security/limits.conf content.
+// generateLimitsConf generates the limits.d drop-in content.
 // Only includes limits defined in templates.
-// Excludes stack/core if set to unlimited to avoid conflicts with /etc/profile.
-func (lca *LimitsConfApplier) generateLimitsConf() string {
-	if len(lca.rlimits) == 0 {
+// Excludes "*" stack/core if set to unlimited to avoid conflicts with /etc/profile.
+// User and @group rules follow the defaults; pam_limits lets them override "*".
+func (lca *LimitsConfApplier) generateLimitsConf(rules []limitRule) string {
+	if len(lca.rlimits) == 0 && !slices.ContainsFunc(rules, func(r limitRule) bool { return r.scope != scopeService }) {
 		return ""
 	}
 
 	var sb strings.Builder
-	sb.WriteString("# Generated by tcsss - System resource limits\n")
+	sb.WriteString("# Managed by tcsss - System resource limits\n")
 	sb.WriteString("# Applied via PAM for new login sessions\n")
 	sb.WriteString("# Note: stack/core unlimited values handled by /etc/profile\n\n")
 
-	// Order matters for readability
-	order := []string{"nofile", "nproc", "memlock", "stack", "core", "cpu", "as", "data", "fsize", "msgqueue", "sigpending", "locks"}
-
-	for _, res := range order {
+	for _, res := range limitOrder {
 		value, ok := lca.rlimits[res]
 		if !ok {
 			continue
@@ -95,37 +109,37 @@ func (lca *LimitsConfApplier) generateLimitsConf() string {
 			continue
 		}
 
-		sb.WriteString(fmt.Sprintf("*  soft  %-12s %s\n", res, value))
-		sb.WriteString(fmt.Sprintf("*  hard  %-12s %s\n", res, value))
+		soft, hard := defaultSoftHard(value)
+		sb.WriteString(fmt.Sprintf("*  soft  %-12s %s\n", res, soft))
+		sb.WriteString(fmt.Sprintf("*  hard  %-12s %s\n", res, hard))
+	}
+
+	for _, rule := range rules {
+		if rule.scope == scopeService {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s  soft  %-12s %s\n", rule.domain(), rule.resource, rule.soft))
+		sb.WriteString(fmt.Sprintf("%s  hard  %-12s %s\n", rule.domain(), rule.resource, rule.hard))
 	}
 
 	return sb.String()
 }
 
-// generateSystemdConf generates /etc/systemd/system.conf content.
+// generateSystemdConf generates the system.conf.d or user.conf.d drop-in content.
 // Only includes limits defined in templates.
-func (lca *LimitsConfApplier) generateSystemdConf() string {
+func (lca *LimitsConfApplier) generateSystemdConf(manager string) string {
 	if len(lca.rlimits) == 0 {
 		return ""
 	}
 
 	var sb strings.Builder
-	sb.WriteString("# Generated by tcsss - systemd default resource limits\n")
-	sb.WriteString("# Reload: systemctl daemon-reexec\n\n")
-	sb.WriteString("[Manager]\n")
-
-	// Map rlimit names to systemd configuration keys
-	mapping := map[string]string{
-		"nofile":  "DefaultLimitNOFILE",
-		"nproc":   "DefaultLimitNPROC",
-		"memlock": "DefaultLimitMEMLOCK",
-		"stack":   "DefaultLimitSTACK",
-		"core":    "DefaultLimitCORE",
-		"cpu":     "DefaultLimitCPU",
-		"as":      "DefaultLimitAS",
-		"data":    "DefaultLimitDATA",
-		"fsize":   "DefaultLimitFSIZE",
+	fmt.Fprintf(&sb, "# Managed by tcsss - systemd %s manager default resource limits\n", manager)
+	if manager == "user" {
+		sb.WriteString("# Takes effect when each user manager next starts\n\n")
+	} else {
+		sb.WriteString("# Reload: systemctl daemon-reexec\n\n")
 	}
+	sb.WriteString("[Manager]\n")
 
 	order := []string{"nofile", "nproc", "memlock", "stack", "core", "cpu", "as", "data", "fsize"}
 
@@ -135,19 +149,14 @@ func (lca *LimitsConfApplier) generateSystemdConf() string {
 			continue
 		}
 
-		// Stack: convert KB to bytes for systemd
-		if key == "stack" && value != "unlimited" {
-			if v, err := strconv.ParseUint(value, 10, 64); err == nil {
-				value = fmt.Sprintf("%d", v*1024)
-			}
-		}
-
-		// Systemd uses "infinity" instead of "unlimited"
-		if value == "unlimited" {
-			value = "infinity"
+		soft, hard := defaultSoftHard(value)
+		soft = systemdLimitValue(key, soft)
+		hard = systemdLimitValue(key, hard)
+		if soft == hard {
+			sb.WriteString(fmt.Sprintf("DefaultLimit%s=%s\n", systemdLimitNames[key], soft))
+		} else {
+			sb.WriteString(fmt.Sprintf("DefaultLimit%s=%s:%s\n", systemdLimitNames[key], soft, hard))
 		}
-
-		sb.WriteString(fmt.Sprintf("%s=%s\n", mapping[key], value))
 	}
 
 	return sb.String()
@@ -157,7 +166,10 @@ func (lca *LimitsConfApplier) generateSystemdConf() string {
 // Only writes limits that are explicitly defined in templates.
 func (lca *LimitsConfApplier) Apply(ctx context.Context) error {
 	// Detect memory tier and load templates
-	templates, err := tmpl.DetectTemplateSet(lca.templateDir)
+	templates, err := tmpl.DetectTemplateSet(lca.templateDir, lca.mode)
+	if errors.Is(err, tmpl.ErrInvalidTemplate) {
+		return err
+	}
 	if err != nil {
 		lca.logger.Warn("memory detection failed, using default tier",
 			slog.String("error", err.Error()))
@@ -165,32 +177,45 @@ func (lca *LimitsConfApplier) Apply(ctx context.Context) error {
 
 	// Load rlimits from templates
 	lca.loadRlimitsFromTemplates(templates)
+	rules, err := lca.extractRules()
+	if err != nil {
+		return err
+	}
 
-	if len(lca.rlimits) == 0 {
+	if len(lca.rlimits) == 0 && len(rules) == 0 {
+		// Carry on so drop-ins written for earlier templates are removed.
 		lca.logger.Warn("no rlimits defined in templates")
-		return nil
+	} else {
+		lca.logger.Info("applying limits configuration",
+			slog.String("memory_tier", templates.MemoryConfig.MemoryLabel),
+			slog.Int("limits", len(lca.rlimits)),
+			slog.Int("rules", len(rules)))
 	}
 
-	lca.logger.Info("applying limits configuration",
-		slog.String("memory_tier", templates.MemoryConfig.MemoryLabel),
-		slog.Int("limits", len(lca.rlimits)))
-
-	// Generate configuration files (only non-empty ones)
+	// Generate drop-ins; empty ones are removed
 	configs := []fileConfig{
 		{
-			path:    "/etc/security/limits.conf",
-			content: lca.generateLimitsConf(),
-			perm:    0644,
+			path:    limitsDropInPath,
+			content: lca.generateLimitsConf(rules),
+			perm:    dropInPerm,
+		},
+		{
+			path:    systemDropInPath,
+			content: lca.generateSystemdConf("system"),
+			perm:    dropInPerm,
+			reexec:  true,
 		},
 		{
-			path:    "/etc/systemd/system.conf",
-			content: lca.generateSystemdConf(),
-			perm:    0644,
+			// User managers read it when they next start.
+			path:    userDropInPath,
+			content: lca.generateSystemdConf("user"),
+			perm:    dropInPerm,
 		},
 	}
 
-	// Write files
-	written := 0
+	reexec := lca.migrateLegacyFiles()
+
+	// Write files whose content changed
 	for _, cfg := range configs {
 		select {
 		case <-ctx.Done():
@@ -199,6 +224,13 @@ func (lca *LimitsConfApplier) Apply(ctx context.Context) error {
 		}
 
 		if cfg.content == "" {
+			if lca.removeManaged(cfg.path) {
+				reexec = reexec || cfg.reexec
+			}
+			continue
+		}
+		if existing, err := os.ReadFile(cfg.path); err == nil && string(existing) == cfg.content {
+			lca.logger.Debug("config unchanged", slog.String("file", cfg.path))
 			continue
 		}
 
@@ -217,10 +249,11 @@ func (lca *LimitsConfApplier) Apply(ctx context.Context) error {
 		}
 
 		lca.logger.Info("config written", slog.String("file", cfg.path))
-		written++
+		reexec = reexec || cfg.reexec
 	}
 
-	if written > 0 {
+	reexecuted := false
+	if reexec {
 		// Reload systemd to apply changes immediately
 		if err := lca.reloadSystemd(ctx); err != nil {
 			lca.logger.Warn("systemd reload failed, manual restart required",
@@ -228,12 +261,92 @@ func (lca *LimitsConfApplier) Apply(ctx context.Context) error {
 				slog.String("action", "run 'systemctl daemon-reexec' manually"))
 		} else {
 			lca.logger.Info("systemd reloaded successfully")
+			reexecuted = true
 		}
 	}
 
+	lca.applyServiceLimits(ctx, rules, reexecuted)
+
 	return nil
 }
 
+// removeManaged deletes a drop-in tcsss wrote, recognised by its header, reporting whether it
+// was removed. Files without the header belong to someone else and are kept.
+func (lca *LimitsConfApplier) removeManaged(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.HasPrefix(string(data), managedMarker) {
+		return false
+	}
+	if err := os.Remove(path); err != nil {
+		lca.logger.Warn("failed to remove stale config",
+			slog.String("file", path),
+			slog.String("error", err.Error()))
+		return false
+	}
+	lca.logger.Info("removed stale config", slog.String("file", path))
+	return true
+}
+
+// migrateLegacyFiles strips what older releases wrote over limits.conf and system.conf,
+// recognised by their generated header, keeping any lines added since. It reports whether
+// system.conf changed, which needs a daemon-reexec.
+func (lca *LimitsConfApplier) migrateLegacyFiles() bool {
+	lca.migrateLegacyFile(legacyLimitsPath, limitsDropInPath, isGeneratedLimitsLine)
+	return lca.migrateLegacyFile(legacySystemdPath, systemDropInPath, isGeneratedSystemdLine)
+}
+
+func (lca *LimitsConfApplier) migrateLegacyFile(path, dropIn string, generated func(string) bool) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.HasPrefix(string(data), generatedMarker) {
+		return false
+	}
+
+	var kept []string
+	header := true
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		// The generated header is the leading run of comments and blank lines.
+		if header && (trimmed == "" || strings.HasPrefix(trimmed, "#")) {
+			continue
+		}
+		header = false
+		if generated(trimmed) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+
+	content := fmt.Sprintf("# tcsss limits moved to %s\n", dropIn)
+	if body := strings.TrimSpace(strings.Join(kept, "\n")); body != "" {
+		content += body + "\n"
+	}
+	if err := writeFileWithSync(path, []byte(content), info.Mode().Perm()); err != nil {
+		lca.logger.Warn("failed to clean up legacy limits file",
+			slog.String("file", path),
+			slog.String("error", err.Error()))
+		return false
+	}
+	lca.logger.Info("removed tcsss limits from legacy file",
+		slog.String("file", path),
+		slog.String("drop_in", dropIn))
+	return true
+}
+
+// isGeneratedLimitsLine matches the "*  soft  nofile  N" entries older releases wrote.
+func isGeneratedLimitsLine(line string) bool {
+	fields := strings.Fields(line)
+	return len(fields) == 4 && fields[0] == "*" && (fields[1] == "soft" || fields[1] == "hard")
+}
+
+// isGeneratedSystemdLine matches the DefaultLimit*= settings older releases wrote.
+func isGeneratedSystemdLine(line string) bool {
+	return strings.HasPrefix(line, "DefaultLimit")
+}
+
 // reloadSystemd executes systemctl daemon-reexec to apply changes immediately
 func (lca *LimitsConfApplier) reloadSystemd(ctx context.Context) error {
 	cmd := exec.CommandContext(ctx, "systemctl", "daemon-reexec")
@@ -0,0 +1,144 @@
+package syslimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// modprobeTimeout bounds each attempt to load a module a value depends on.
+const modprobeTimeout = 2 * time.Second
+
+// keyModules name the module that provides a family of keys, to explain why they are missing.
+var keyModules = []struct {
+	prefix string
+	module string
+}{
+	{prefix: "net.netfilter.nf_conntrack_", module: "nf_conntrack"},
+	{prefix: "net.bridge.bridge-nf-", module: "br_netfilter"},
+	{prefix: "net.ipv4.vs.", module: "ip_vs"},
+}
+
+// valuePrerequisites check that a value can take effect before it is written, for keys whose
+// values name kernel modules.
+var valuePrerequisites = map[string]func(root, value string) error{
+	"net.core.default_qdisc":                  qdiscAvailable,
+	"net.ipv4.tcp_congestion_control":         congctlAvailable,
+	"net.ipv4.tcp_allowed_congestion_control": congctlAvailable,
+}
+
+// missingReason explains why a key has no /proc/sys file. Outside the initial network
+// namespace the kernel only exposes namespaced net.* keys, so the others are not a kernel gap.
+func missingReason(root, key string) (KeyStatus, string) {
+	if strings.HasPrefix(key, "net.") && !inInitialNetns(root) {
+		return KeyNotNamespaced, "global key, not visible in this network namespace"
+	}
+	for _, entry := range keyModules {
+		if strings.HasPrefix(key, entry.prefix) {
+			return KeyUnsupported, fmt.Sprintf("module %s not loaded", entry.module)
+		}
+	}
+	return KeyUnsupported, "not provided by this kernel"
+}
+
+// inInitialNetns reports whether /proc/sys shows the initial network namespace, judged by
+// netdev_max_backlog: it is global and only registered there, unlike somaxconn.
+func inInitialNetns(root string) bool {
+	if _, err := os.Stat(filepath.Join(root, "net", "core", "somaxconn")); err != nil {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(root, "net", "core", "netdev_max_backlog"))
+	return err == nil
+}
+
+// writableReason reports why a key cannot be written, or "" when it can. Containers usually
+// mount /proc/sys read-only or leave global keys owned by the host.
+func writableReason(path string) string {
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0o222 == 0 {
+		return "read-only key"
+	}
+	switch err := unix.Access(path, unix.W_OK); {
+	case err == nil:
+		return ""
+	case errors.Is(err, unix.EROFS):
+		return "/proc/sys is mounted read-only"
+	case errors.Is(err, unix.EACCES), errors.Is(err, unix.EPERM):
+		return "not writable from this namespace"
+	}
+	return ""
+}
+
+// checkPrerequisite runs the value check registered for a key, if any.
+func checkPrerequisite(root, key, value string) error {
+	check, ok := valuePrerequisites[key]
+	if !ok {
+		return nil
+	}
+	return check(root, value)
+}
+
+// qdiscAvailable requires the sch_<name> module of a default qdisc, loading it when needed.
+// pfifo_fast is built into every kernel.
+func qdiscAvailable(_ string, value string) error {
+	name := strings.TrimSpace(value)
+	if name == "" || name == "pfifo_fast" {
+		return nil
+	}
+	if moduleLoaded("sch_" + name) {
+		return nil
+	}
+	if err := modprobe("sch_" + name); err != nil {
+		return fmt.Errorf("qdisc %s requires sch_%s: %w", name, name, err)
+	}
+	return nil
+}
+
+// congctlAvailable requires every listed algorithm in tcp_available_congestion_control,
+// loading tcp_<algo> for the ones not listed yet.
+func congctlAvailable(root string, value string) error {
+	for _, algo := range strings.Fields(value) {
+		if listsAlgorithm(root, algo) {
+			continue
+		}
+		if err := modprobe("tcp_" + algo); err != nil || !listsAlgorithm(root, algo) {
+			return fmt.Errorf("congestion control %s is not available", algo)
+		}
+	}
+	return nil
+}
+
+func listsAlgorithm(root, algo string) bool {
+	available, err := readProcSys(root, "net.ipv4.tcp_available_congestion_control")
+	if err != nil {
+		// Without the list the kernel is the judge; a bad name is rejected on write.
+		return true
+	}
+	return slices.Contains(strings.Fields(available), algo)
+}
+
+func moduleLoaded(name string) bool {
+	_, err := os.Stat(filepath.Join("/sys/module", name))
+	return err == nil
+}
+
+func modprobe(module string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), modprobeTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "modprobe", module).CombinedOutput()
+	if err != nil {
+		if trimmed := strings.TrimSpace(string(output)); trimmed != "" {
+			return fmt.Errorf("modprobe %s: %s", module, trimmed)
+		}
+		return fmt.Errorf("modprobe %s: %w", module, err)
+	}
+	return nil
+}
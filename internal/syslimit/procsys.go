@@ -0,0 +1,207 @@
+package syslimit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// procSysRoot is where the kernel exposes sysctl parameters.
+const procSysRoot = "/proc/sys"
+
+// criticalKey lists, in any template, the sysctl keys whose failure aborts startup.
+const criticalKey = "sysctl.critical"
+
+// KeyStatus is the outcome of applying one sysctl key.
+type KeyStatus string
+
+const (
+	// KeyApplied means the key was written and reads back as configured.
+	KeyApplied KeyStatus = "applied"
+	// KeyUnchanged means the kernel already held the configured value.
+	KeyUnchanged KeyStatus = "unchanged"
+	// KeyUnsupported means the running kernel has no such key.
+	KeyUnsupported KeyStatus = "unsupported"
+	// KeyNotNamespaced means the key is global and hidden from this network namespace.
+	KeyNotNamespaced KeyStatus = "not-namespaced"
+	// KeyReadOnly means the key cannot be written here, typically inside a container.
+	KeyReadOnly KeyStatus = "read-only"
+	// KeyPrerequisite means the value depends on something the kernel lacks, such as a qdisc module.
+	KeyPrerequisite KeyStatus = "prerequisite-missing"
+	// KeyRejected means the kernel refused the value or stored a different one.
+	KeyRejected KeyStatus = "rejected"
+	// KeyOverridden means the value was changed by another sysctl file during reload.
+	KeyOverridden KeyStatus = "overridden"
+)
+
+// KeyResult reports how one managed sysctl key was applied.
+type KeyResult struct {
+	Key      string    `json:"key"`
+	Want     string    `json:"want"`
+	Got      string    `json:"got,omitempty"`
+	Status   KeyStatus `json:"status"`
+	Critical bool      `json:"critical,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Failed reports whether the key did not end up with the configured value.
+func (r KeyResult) Failed() bool {
+	return r.Status != KeyApplied && r.Status != KeyUnchanged
+}
+
+// Skipped reports whether the key was left alone because it cannot be applied on this host.
+func (r KeyResult) Skipped() bool {
+	switch r.Status {
+	case KeyUnsupported, KeyNotNamespaced, KeyReadOnly, KeyPrerequisite:
+		return true
+	}
+	return false
+}
+
+// procSysPath maps a sysctl key to its /proc/sys file. As in sysctl(8), dots separate path
+// components and slashes stand for literal dots, as in net.ipv4.conf.eth0/100.rp_filter.
+func procSysPath(root, key string) string {
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		parts[i] = strings.ReplaceAll(part, "/", ".")
+	}
+	return filepath.Join(append([]string{root}, parts...)...)
+}
+
+// normalizeValue collapses whitespace so multi-field values such as tcp_rmem compare equal
+// whether written with spaces or read back with tabs.
+func normalizeValue(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+func readProcSys(root, key string) (string, error) {
+	data, err := os.ReadFile(procSysPath(root, key))
+	if err != nil {
+		return "", err
+	}
+	return normalizeValue(string(data)), nil
+}
+
+// applyKey writes one key to /proc/sys and reads it back. Keys that are missing, not
+// writable here or whose value lacks a prerequisite are skipped with a reason.
+func applyKey(root, key, want string) KeyResult {
+	result := KeyResult{Key: key, Want: normalizeValue(want)}
+
+	current, err := readProcSys(root, key)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			result.Status, result.Reason = missingReason(root, key)
+		} else {
+			result.Status = KeyRejected
+			result.Error = err.Error()
+		}
+		return result
+	}
+	result.Got = current
+	if current == result.Want {
+		result.Status = KeyUnchanged
+		// The value is right, but `sysctl --system` would still fail writing it.
+		result.Reason = writableReason(procSysPath(root, key))
+		return result
+	}
+	if reason := writableReason(procSysPath(root, key)); reason != "" {
+		result.Status = KeyReadOnly
+		result.Reason = reason
+		return result
+	}
+	if err := checkPrerequisite(root, key, result.Want); err != nil {
+		result.Status = KeyPrerequisite
+		result.Reason = err.Error()
+		return result
+	}
+
+	if err := os.WriteFile(procSysPath(root, key), []byte(result.Want+"\n"), 0); err != nil {
+		result.Status = KeyRejected
+		result.Error = describeWriteError(err)
+		return result
+	}
+
+	got, err := readProcSys(root, key)
+	if err != nil {
+		result.Status = KeyRejected
+		result.Error = err.Error()
+		return result
+	}
+	result.Got = got
+	if got != result.Want {
+		result.Status = KeyRejected
+		result.Error = "kernel stored a different value"
+		return result
+	}
+	result.Status = KeyApplied
+	return result
+}
+
+func describeWriteError(err error) string {
+	switch {
+	case errors.Is(err, syscall.EINVAL):
+		return "invalid value (EINVAL)"
+	case errors.Is(err, syscall.EPERM), errors.Is(err, syscall.EACCES):
+		return "permission denied (EPERM)"
+	case errors.Is(err, syscall.ENOENT):
+		// Written as a value name the kernel does not know, e.g. an unloaded congestion control.
+		return "unknown value (ENOENT)"
+	}
+	return err.Error()
+}
+
+// verifyAfterReload marks keys whose value changed after `sysctl --system` as overridden.
+func verifyAfterReload(root string, results []KeyResult) {
+	for i := range results {
+		result := &results[i]
+		if result.Failed() {
+			continue
+		}
+		got, err := readProcSys(root, result.Key)
+		if err != nil || got == result.Want {
+			continue
+		}
+		result.Got = got
+		result.Status = KeyOverridden
+		result.Error = "changed by another sysctl file"
+	}
+}
+
+// parseCritical collects the keys listed by sysctl.critical entries across templates.
+func parseCritical(templates ...string) map[string]bool {
+	critical := make(map[string]bool)
+	for _, tpl := range templates {
+		for _, line := range strings.Split(tpl, "\n") {
+			line = strings.TrimSpace(line)
+			if idx := strings.Index(line, "#"); idx >= 0 {
+				line = strings.TrimSpace(line[:idx])
+			}
+			key, value, ok := strings.Cut(line, "=")
+			if !ok || strings.TrimSpace(key) != criticalKey {
+				continue
+			}
+			for _, name := range strings.Fields(strings.ReplaceAll(value, ",", " ")) {
+				critical[name] = true
+			}
+		}
+	}
+	return critical
+}
+
+// criticalFailures returns an error naming every critical key that failed.
+func criticalFailures(results []KeyResult) error {
+	var failed []string
+	for _, result := range results {
+		if result.Critical && result.Failed() {
+			failed = append(failed, fmt.Sprintf("%s (%s)", result.Key, result.Status))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("critical sysctl keys not applied: %s", strings.Join(failed, ", "))
+}
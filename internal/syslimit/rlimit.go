@@ -2,6 +2,7 @@ package syslimit
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strconv"
@@ -18,11 +19,12 @@ import (
 type RlimitApplier struct {
 	logger      *slog.Logger
 	templateDir string
+	mode        tmpl.TrafficMode
 }
 
 // NewRlimitApplier creates a new RlimitApplier instance.
-func NewRlimitApplier(logger *slog.Logger, templateDir string) *RlimitApplier {
-	return &RlimitApplier{logger: logger, templateDir: templateDir}
+func NewRlimitApplier(logger *slog.Logger, templateDir string, mode tmpl.TrafficMode) *RlimitApplier {
+	return &RlimitApplier{logger: logger, templateDir: templateDir, mode: mode}
 }
 
 // limitConfig holds soft and hard limit values.
@@ -66,8 +68,9 @@ func parseRlimitValue(value string) (uint64, error) {
 }
 
 // parseRlimitConfig parses rlimit configuration from template content.
-// Only extracts rlimit.* entries, skipping comments and other parameters.
-// Format: rlimit.<resource>=<value>
+// Only extracts rlimit.* entries, skipping comments, other parameters and the
+// user, group and service rules, which do not concern this process.
+// Format: rlimit.<resource>=<value> or rlimit.<resource>=<soft>:<hard>
 func (rla *RlimitApplier) parseRlimitConfig(content string) []limitConfig {
 	var limits []limitConfig
 
@@ -95,21 +98,28 @@ func (rla *RlimitApplier) parseRlimitConfig(content string) []limitConfig {
 		}
 
 		// Parse value
-		value, err := parseRlimitValue(valueStr)
+		softStr, hardStr, err := splitSoftHard(valueStr)
 		if err != nil {
 			continue
 		}
+		soft, _ := parseRlimitValue(softStr)
+		hard, _ := parseRlimitValue(hardStr)
 
 		// Stack values in templates are in KB, convert to bytes
-		if resourceName == "stack" && value != unlimited {
-			value *= 1024
+		if resourceName == "stack" {
+			if soft != unlimited {
+				soft *= 1024
+			}
+			if hard != unlimited {
+				hard *= 1024
+			}
 		}
 
 		limits = append(limits, limitConfig{
 			resource: resource,
 			name:     "RLIMIT_" + strings.ToUpper(resourceName),
-			soft:     value,
-			hard:     value,
+			soft:     soft,
+			hard:     hard,
 		})
 	}
 
@@ -121,7 +131,10 @@ func (rla *RlimitApplier) parseRlimitConfig(content string) []limitConfig {
 // Only modifies limits that are explicitly defined in templates.
 func (rla *RlimitApplier) Apply(ctx context.Context) error {
 	// Detect memory tier and load templates
-	templates, err := tmpl.DetectTemplateSet(rla.templateDir)
+	templates, err := tmpl.DetectTemplateSet(rla.templateDir, rla.mode)
+	if errors.Is(err, tmpl.ErrInvalidTemplate) {
+		return err
+	}
 	if err != nil {
 		rla.logger.Warn("memory detection failed, using default tier",
 			slog.String("error", err.Error()))
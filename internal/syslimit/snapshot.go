@@ -0,0 +1,225 @@
+package syslimit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultStateDir holds state tcsss keeps across restarts, such as the sysctl snapshot.
+	DefaultStateDir = "/var/lib/tcsss"
+	snapshotName    = "sysctl-snapshot.json"
+)
+
+// SysctlSnapshot records what tcsss replaced: the live value of every key before tcsss first
+// changed it and the drop-in in place before the first apply. Rolling back restores both.
+type SysctlSnapshot struct {
+	TakenAt time.Time         `json:"taken_at"`
+	Path    string            `json:"path"`
+	DropIn  *string           `json:"drop_in"`
+	Values  map[string]string `json:"values"`
+}
+
+// snapshotPath returns the snapshot file inside a state directory.
+func snapshotPath(stateDir string) string {
+	return filepath.Join(stateDir, snapshotName)
+}
+
+// takeSnapshot reads the live value of every key that differs from the configured one, plus
+// the current drop-in. Unsupported keys are left out since there is nothing to restore.
+func (sca *SysctlConfApplier) takeSnapshot(params map[string]string) SysctlSnapshot {
+	snapshot := SysctlSnapshot{
+		TakenAt: time.Now().UTC(),
+		Path:    sca.path,
+		Values:  make(map[string]string),
+	}
+	if data, err := os.ReadFile(sca.path); err == nil {
+		content := string(data)
+		snapshot.DropIn = &content
+	}
+	for key, want := range params {
+		current, err := readProcSys(sca.procRoot, key)
+		if err != nil || current == normalizeValue(want) {
+			continue
+		}
+		snapshot.Values[key] = current
+	}
+	return snapshot
+}
+
+// recordSnapshot saves the first snapshot for `tcsss sysctl rollback`. Failing to save it is
+// not fatal; the in-memory copy still covers the automatic rollback of this apply.
+func (sca *SysctlConfApplier) recordSnapshot(snapshot SysctlSnapshot) {
+	err := saveSnapshot(sca.stateDir, snapshot)
+	if sca.logger == nil {
+		return
+	}
+	if err != nil {
+		sca.logger.Warn("sysctl snapshot not saved", slog.String("error", err.Error()))
+		return
+	}
+	sca.logger.Info("sysctl snapshot saved",
+		slog.String("path", snapshotPath(sca.stateDir)),
+		slog.Int("keys", len(snapshot.Values)))
+}
+
+// mergeSnapshot adds the keys of an apply's snapshot to the saved one, or saves it when there is
+// none. Values the saved snapshot already holds, and its drop-in, are older and kept; replacing
+// them would leave rollback restoring tcsss's own settings.
+func (sca *SysctlConfApplier) mergeSnapshot(snapshot SysctlSnapshot) {
+	saved, err := LoadSysctlSnapshot(sca.stateDir)
+	if err != nil {
+		if _, statErr := os.Stat(snapshotPath(sca.stateDir)); errors.Is(statErr, os.ErrNotExist) {
+			sca.recordSnapshot(snapshot)
+		} else if sca.logger != nil {
+			sca.logger.Warn("sysctl snapshot not updated", slog.String("error", err.Error()))
+		}
+		return
+	}
+
+	if saved.Values == nil {
+		saved.Values = make(map[string]string)
+	}
+	added := 0
+	for key, value := range snapshot.Values {
+		if _, ok := saved.Values[key]; ok {
+			continue
+		}
+		saved.Values[key] = value
+		added++
+	}
+	if added == 0 {
+		return
+	}
+
+	err = saveSnapshot(sca.stateDir, saved)
+	if sca.logger == nil {
+		return
+	}
+	if err != nil {
+		sca.logger.Warn("sysctl snapshot not updated", slog.String("error", err.Error()))
+		return
+	}
+	sca.logger.Info("sysctl snapshot updated",
+		slog.String("path", snapshotPath(sca.stateDir)),
+		slog.Int("added_keys", added))
+}
+
+// saveSnapshot persists a snapshot in the state directory, replacing the previous one.
+func saveSnapshot(stateDir string, snapshot SysctlSnapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode sysctl snapshot: %w", err)
+	}
+	if err := os.MkdirAll(stateDir, 0o700); err != nil {
+		return fmt.Errorf("create %s: %w", stateDir, err)
+	}
+
+	path := snapshotPath(stateDir)
+	tmp := path + ".tmp"
+	if err := writeFileWithSync(tmp, append(data, '\n'), filePerm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("rename %s: %w", tmp, err)
+	}
+	return nil
+}
+
+// LoadSysctlSnapshot reads the snapshot accumulated since tcsss first changed sysctl settings.
+func LoadSysctlSnapshot(stateDir string) (SysctlSnapshot, error) {
+	var snapshot SysctlSnapshot
+	path := snapshotPath(stateDir)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return snapshot, fmt.Errorf("no sysctl snapshot at %s", path)
+		}
+		return snapshot, fmt.Errorf("read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+// restoreSnapshot writes the recorded values back to /proc/sys and puts the recorded drop-in
+// back in place, removing it when none existed before.
+func restoreSnapshot(root string, snapshot SysctlSnapshot) ([]KeyResult, error) {
+	keys := make([]string, 0, len(snapshot.Values))
+	for key := range snapshot.Values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	results := make([]KeyResult, 0, len(keys))
+	var failed []string
+	for _, key := range keys {
+		result := applyKey(root, key, snapshot.Values[key])
+		if result.Failed() && !result.Skipped() {
+			failed = append(failed, key)
+		}
+		results = append(results, result)
+	}
+
+	var err error
+	switch {
+	case snapshot.Path == "":
+	case snapshot.DropIn != nil:
+		if writeErr := writeFileWithSync(snapshot.Path, []byte(*snapshot.DropIn), dropInPerm); writeErr != nil {
+			err = fmt.Errorf("restore sysctl drop-in: %w", writeErr)
+		}
+	default:
+		if removeErr := os.Remove(snapshot.Path); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+			err = fmt.Errorf("remove sysctl drop-in: %w", removeErr)
+		}
+	}
+	if err == nil && len(failed) > 0 {
+		err = fmt.Errorf("sysctl keys not restored: %s", strings.Join(failed, ", "))
+	}
+	return results, err
+}
+
+// RollbackSysctl restores the snapshot in stateDir, live and in the drop-in it names, and
+// removes the snapshot once everything was restored.
+func RollbackSysctl(stateDir string) (SysctlSnapshot, []KeyResult, error) {
+	snapshot, err := LoadSysctlSnapshot(stateDir)
+	if err != nil {
+		return snapshot, nil, err
+	}
+	results, err := restoreSnapshot(procSysRoot, snapshot)
+	if err != nil {
+		return snapshot, results, err
+	}
+	if err := os.Remove(snapshotPath(stateDir)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return snapshot, results, fmt.Errorf("remove sysctl snapshot: %w", err)
+	}
+	return snapshot, results, nil
+}
+
+// rollbackAfterFailure restores the snapshot of the current apply after writing or reloading
+// the drop-in failed, so the kernel is not left half-configured.
+func (sca *SysctlConfApplier) rollbackAfterFailure(snapshot SysctlSnapshot, cause error) {
+	results, err := restoreSnapshot(sca.procRoot, snapshot)
+	if sca.logger == nil {
+		return
+	}
+	if err != nil {
+		sca.logger.Error("sysctl rollback incomplete",
+			slog.String("cause", cause.Error()),
+			slog.String("error", err.Error()))
+		return
+	}
+	sca.logger.Warn("sysctl changes rolled back",
+		slog.String("cause", cause.Error()),
+		slog.Int("keys", len(results)),
+		slog.String("path", snapshot.Path))
+}
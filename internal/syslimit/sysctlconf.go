@@ -2,20 +2,31 @@ package syslimit
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	tmpl "tcsss/internal/config"
 )
 
 const (
-	sysctlConfPath = "/etc/sysctl.conf"
-	filePerm       = 0o600
+	// DefaultSysctlPath is the drop-in owned by tcsss; sysctl.conf is left to the administrator.
+	DefaultSysctlPath = "/etc/sysctl.d/99-tcsss.conf"
+	legacySysctlPath  = "/etc/sysctl.conf"
+	dropInPerm        = 0o644
+	filePerm          = 0o600
+
+	sysctlHeader = "# Managed by tcsss; local changes are overwritten. Override keys in a later sysctl.d file."
 )
 
+// legacyMarkers are the comments older releases wrote when merging into sysctl.conf.
+var legacyMarkers = []string{"# tcsss managed sysctl parameters", "# tcsss managed parameters"}
+
 // writeFileWithSync truncates the target file, writes the payload, and fsyncs it.
 func writeFileWithSync(path string, data []byte, perm os.FileMode) error {
 	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
@@ -58,8 +69,12 @@ func reloadSysctl(ctx context.Context) (string, error) {
 type SysctlConfApplier struct {
 	logger      *slog.Logger
 	path        string
+	legacyPath  string
+	procRoot    string
+	stateDir    string
 	mode        tmpl.TrafficMode
 	templateDir string
+	report      []KeyResult
 }
 
 // NewSysctlConfApplier creates a new applier.
@@ -69,53 +84,145 @@ func NewSysctlConfApplier(logger *slog.Logger, templateDir string, mode tmpl.Tra
 	}
 	return &SysctlConfApplier{
 		logger:      logger,
-		path:        sysctlConfPath,
+		path:        DefaultSysctlPath,
+		legacyPath:  legacySysctlPath,
+		procRoot:    procSysRoot,
+		stateDir:    DefaultStateDir,
 		mode:        mode,
 		templateDir: templateDir,
 	}
 }
 
-// SetSysctlPath overrides the default path (for testing).
+// SetSysctlPath overrides the drop-in path.
 func (sca *SysctlConfApplier) SetSysctlPath(path string) {
 	if path != "" {
 		sca.path = path
 	}
 }
 
-// Apply writes sysctl.conf from templates based on memory tier.
+// Apply writes the sysctl drop-in from templates based on memory tier.
 func (sca *SysctlConfApplier) Apply(ctx context.Context) error {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
 
-	tplSet, detectErr := tmpl.DetectTemplateSet(sca.templateDir)
+	tplSet, detectErr := tmpl.DetectTemplateSet(sca.templateDir, sca.mode)
+	if errors.Is(detectErr, tmpl.ErrInvalidTemplate) {
+		return detectErr
+	}
 	sca.logDetectionFallback(detectErr)
+	sca.logTemplateSelection(tplSet)
 
-	params, err := sca.buildTemplateParameters(tplSet)
+	params, critical, err := sca.buildTemplateParameters(tplSet)
 	if err != nil {
 		return err
 	}
 
+	migrated := sca.migrateLegacyConfig(params)
+	sca.warnLegacyOverrides(params)
+
 	existing := sca.loadExistingConfig()
-	merged := merge(existing, params)
+	snapshot := sca.takeSnapshot(params)
+
+	// Keys are written directly so each one gets a verdict; the drop-in makes them persistent.
+	results := sca.applyDirect(params, critical)
+
+	rendered := render(params, skippedReasons(results))
+	persist := migrated || !sca.isConfigUnchanged(existing, rendered)
 
-	if sca.isConfigUnchanged(existing, merged) {
+	if !persist {
 		sca.logConfigUnchanged()
-		return nil
+		if len(snapshot.Values) > 0 {
+			sca.mergeSnapshot(snapshot)
+		}
+	} else {
+		if err := sca.writeConfigAndReload(ctx, rendered, params, tplSet); err != nil {
+			sca.rollbackAfterFailure(snapshot, err)
+			return err
+		}
+		// Saved only now: a failed apply was rolled back and has nothing left to restore.
+		sca.mergeSnapshot(snapshot)
+		verifyAfterReload(sca.procRoot, results)
+
+		if err := sca.setTransparentHugepage(ctx); err != nil {
+			if sca.logger != nil {
+				sca.logger.Warn("failed to set transparent hugepage", slog.String("error", err.Error()))
+			}
+			// Non-fatal: continue even if this fails
+		}
 	}
 
-	if err := sca.writeConfigAndReload(ctx, merged, params, tplSet); err != nil {
-		return err
+	sca.logReport(results)
+	return criticalFailures(results)
+}
+
+// Report returns the per-key results of the last Apply.
+func (sca *SysctlConfApplier) Report() []KeyResult {
+	return append([]KeyResult(nil), sca.report...)
+}
+
+// applyDirect writes every parameter to /proc/sys in key order and reads it back.
+func (sca *SysctlConfApplier) applyDirect(params map[string]string, critical map[string]bool) []KeyResult {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
-	if err := sca.setTransparentHugepage(ctx); err != nil {
-		if sca.logger != nil {
-			sca.logger.Warn("failed to set transparent hugepage", slog.String("error", err.Error()))
+	results := make([]KeyResult, 0, len(keys))
+	for _, key := range keys {
+		result := applyKey(sca.procRoot, key, params[key])
+		result.Critical = critical[key]
+		results = append(results, result)
+	}
+	return results
+}
+
+// logReport logs a summary of the per-key results plus every key that did not take effect.
+func (sca *SysctlConfApplier) logReport(results []KeyResult) {
+	sca.report = results
+	if sca.logger == nil {
+		return
+	}
+
+	counts := make(map[KeyStatus]int)
+	for _, result := range results {
+		counts[result.Status]++
+		attrs := []any{
+			slog.String("key", result.Key),
+			slog.String("want", result.Want),
+			slog.String("status", string(result.Status)),
+		}
+		if result.Got != "" {
+			attrs = append(attrs, slog.String("got", result.Got))
+		}
+		if result.Reason != "" {
+			attrs = append(attrs, slog.String("reason", result.Reason))
+		}
+		if result.Error != "" {
+			attrs = append(attrs, slog.String("error", result.Error))
+		}
+		switch {
+		case result.Failed() && result.Critical:
+			sca.logger.Error("critical sysctl key not applied", attrs...)
+		case result.Skipped():
+			sca.logger.Info("sysctl key skipped", attrs...)
+		case result.Failed():
+			sca.logger.Warn("sysctl key not applied", attrs...)
+		case result.Status == KeyApplied:
+			sca.logger.Debug("sysctl key applied", attrs...)
 		}
-		// Non-fatal: continue even if this fails
 	}
 
-	return nil
+	sca.logger.Info("sysctl keys verified",
+		slog.Int("applied", counts[KeyApplied]),
+		slog.Int("unchanged", counts[KeyUnchanged]),
+		slog.Int("unsupported", counts[KeyUnsupported]),
+		slog.Int("not_namespaced", counts[KeyNotNamespaced]),
+		slog.Int("read_only", counts[KeyReadOnly]),
+		slog.Int("prerequisite_missing", counts[KeyPrerequisite]),
+		slog.Int("rejected", counts[KeyRejected]),
+		slog.Int("overridden", counts[KeyOverridden]))
 }
 
 func (sca *SysctlConfApplier) logDetectionFallback(err error) {
@@ -124,16 +231,43 @@ func (sca *SysctlConfApplier) logDetectionFallback(err error) {
 	}
 }
 
-func (sca *SysctlConfApplier) buildTemplateParameters(tplSet tmpl.TemplateSet) (map[string]string, error) {
+// logTemplateSelection explains which limits template was chosen and why the others were not.
+func (sca *SysctlConfApplier) logTemplateSelection(tplSet tmpl.TemplateSet) {
+	if sca.logger == nil || tplSet.MemoryConfig.Filename == "" {
+		return
+	}
+	selection := tplSet.Selection
+	sca.logger.Info("limits template selected",
+		slog.String("template", tplSet.MemoryConfig.Filename),
+		slog.Int("score", selection.Score),
+		slog.String("reasons", strings.Join(selection.Reasons, "; ")),
+		slog.Bool("fallback", selection.Fallback),
+		slog.Int("cpus", selection.Facts.CPUs),
+		slog.Int("link_speed_mbps", selection.Facts.LinkSpeedMbps),
+		slog.Bool("virtualized", selection.Facts.Virtualized))
+
+	rejected := make([]string, 0, len(selection.Rejected))
+	for name := range selection.Rejected {
+		rejected = append(rejected, name)
+	}
+	sort.Strings(rejected)
+	for _, name := range rejected {
+		sca.logger.Debug("limits template not selected",
+			slog.String("template", name),
+			slog.String("reason", selection.Rejected[name]))
+	}
+}
+
+func (sca *SysctlConfApplier) buildTemplateParameters(tplSet tmpl.TemplateSet) (map[string]string, map[string]bool, error) {
 	roleTemplate, err := tmpl.TrafficTemplateContent(sca.templateDir, sca.mode)
 	if err != nil {
-		return nil, fmt.Errorf("load traffic template: %w", err)
+		return nil, nil, fmt.Errorf("load traffic template: %w", err)
 	}
 	params := parseTemplate(tplSet.Common, tplSet.Specific, roleTemplate)
 	if len(params) == 0 {
-		return nil, fmt.Errorf("no parameters in templates")
+		return nil, nil, fmt.Errorf("no parameters in templates")
 	}
-	return params, nil
+	return params, parseCritical(tplSet.Common, tplSet.Specific, roleTemplate), nil
 }
 
 func (sca *SysctlConfApplier) loadExistingConfig() string {
@@ -150,21 +284,27 @@ func (sca *SysctlConfApplier) isConfigUnchanged(existing, merged string) bool {
 
 func (sca *SysctlConfApplier) logConfigUnchanged() {
 	if sca.logger != nil {
-		sca.logger.Info("sysctl.conf already up to date")
+		sca.logger.Info("sysctl drop-in already up to date", slog.String("path", sca.path))
 	}
 }
 
-func (sca *SysctlConfApplier) writeConfigAndReload(ctx context.Context, merged string, params map[string]string, tplSet tmpl.TemplateSet) error {
-	if err := writeFileWithSync(sca.path, []byte(merged), filePerm); err != nil {
-		return fmt.Errorf("persist sysctl.conf: %w", err)
+func (sca *SysctlConfApplier) writeConfigAndReload(ctx context.Context, rendered string, params map[string]string, tplSet tmpl.TemplateSet) error {
+	if err := os.MkdirAll(filepath.Dir(sca.path), 0o755); err != nil {
+		return fmt.Errorf("create %s: %w", filepath.Dir(sca.path), err)
+	}
+	if err := writeFileWithSync(sca.path, []byte(rendered), dropInPerm); err != nil {
+		return fmt.Errorf("persist sysctl drop-in: %w", err)
 	}
 
 	if sca.logger != nil {
-		sca.logger.Info("sysctl.conf updated",
+		sca.logger.Info("sysctl drop-in updated",
+			slog.String("path", sca.path),
 			slog.Int("params", len(params)),
 			slog.String("memory_tier", tplSet.MemoryConfig.MemoryLabel),
 			slog.Float64("system_memory_gb", tplSet.SystemMemoryGB),
 			slog.Float64("effective_memory_gb", tplSet.EffectiveMemoryGB),
+			slog.Int("cpus", tplSet.Vars.CPUs),
+			slog.Int("link_speed_mbps", tplSet.Vars.LinkSpeedMbps),
 			slog.String("mode", string(sca.mode)))
 	}
 
@@ -179,8 +319,9 @@ func (sca *SysctlConfApplier) writeConfigAndReload(ctx context.Context, merged s
 func (sca *SysctlConfApplier) handleReloadResult(output string, err error) error {
 	if err != nil {
 		if strings.Contains(output, "sysctl: cannot stat") {
+			// Missing keys are reported individually as unsupported.
 			if sca.logger != nil {
-				sca.logger.Warn("sysctl apply completed with missing kernel parameters",
+				sca.logger.Debug("sysctl --system skipped missing kernel parameters",
 					slog.String("details", output))
 			}
 			return nil
@@ -233,7 +374,7 @@ func parseTemplate(templates ...string) map[string]string {
 
 // isSysctlKey returns true when the key looks like a kernel parameter.
 func isSysctlKey(key string) bool {
-	if strings.HasPrefix(key, "rlimit.") {
+	if strings.HasPrefix(key, "rlimit.") || strings.HasPrefix(key, "sysctl.") {
 		return false
 	}
 	// Sysctl keys always use dot-separated namespace (e.g. net.ipv4.tcp_sack)
@@ -244,68 +385,167 @@ func isSysctlKey(key string) bool {
 	return !strings.ContainsAny(key, " \t")
 }
 
-// merge updates template parameters in existing config, preserving other lines.
-func merge(existing string, params map[string]string) string {
-	if existing == "" {
-		// No existing config, create new
-		var lines []string
-		lines = append(lines, "# tcsss managed sysctl parameters")
-		for key, value := range params {
-			lines = append(lines, fmt.Sprintf("%s = %s", key, value))
+// render formats parameters as a sysctl.d file sorted by key, so unchanged templates always
+// produce identical content. Skipped keys are written as comments with their reason, since
+// systemd-sysctl would fail on them at every boot.
+func render(params map[string]string, skipped map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(sysctlHeader + "\n")
+	for _, key := range keys {
+		if reason, ok := skipped[key]; ok {
+			fmt.Fprintf(&b, "# %s = %s (skipped: %s)\n", key, params[key], reason)
+			continue
 		}
-		return strings.Join(lines, "\n") + "\n"
+		fmt.Fprintf(&b, "%s = %s\n", key, params[key])
 	}
+	return b.String()
+}
 
-	var output []string
-	updated := make(map[string]bool)
+// skippedReasons maps every key skipped on this host to its reason, including keys that
+// already hold their value but are not writable here.
+func skippedReasons(results []KeyResult) map[string]string {
+	skipped := make(map[string]string)
+	for _, result := range results {
+		if result.Skipped() || result.Status == KeyUnchanged && result.Reason != "" {
+			skipped[result.Key] = result.Reason
+		}
+	}
+	return skipped
+}
 
-	// Process existing lines
-	for _, line := range strings.Split(existing, "\n") {
-		trimmed := strings.TrimSpace(line)
+// migrateLegacyConfig removes the parameters older releases merged into sysctl.conf. It runs
+// only while their marker comment is present, so it happens once and never touches a
+// sysctl.conf tcsss did not write. It reports whether sysctl.conf was rewritten.
+func (sca *SysctlConfApplier) migrateLegacyConfig(params map[string]string) bool {
+	if sca.legacyPath == "" || sca.legacyPath == sca.path {
+		return false
+	}
+	info, err := os.Stat(sca.legacyPath)
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(sca.legacyPath)
+	if err != nil {
+		return false
+	}
 
-		// Skip empty lines and comments
+	cleaned, removed, ok := stripLegacyParams(string(data), params)
+	if !ok {
+		return false
+	}
+	if err := writeFileWithSync(sca.legacyPath, []byte(cleaned), info.Mode().Perm()); err != nil {
+		if sca.logger != nil {
+			sca.logger.Warn("failed to migrate legacy sysctl.conf parameters",
+				slog.String("path", sca.legacyPath),
+				slog.String("error", err.Error()))
+		}
+		return false
+	}
+	if sca.logger != nil {
+		sca.logger.Info("migrated tcsss parameters out of sysctl.conf",
+			slog.String("path", sca.legacyPath),
+			slog.String("drop_in", sca.path),
+			slog.Int("removed", len(removed)))
+	}
+	return true
+}
+
+// stripLegacyParams drops the marker comments, the key lines of the block each marker starts,
+// and every line setting a current parameter, which older releases rewrote in place.
+func stripLegacyParams(content string, params map[string]string) (string, []string, bool) {
+	lines := strings.Split(content, "\n")
+	marked := false
+	for _, line := range lines {
+		if isLegacyMarker(line) {
+			marked = true
+			break
+		}
+	}
+	if !marked {
+		return content, nil, false
+	}
+
+	var output, removed []string
+	inBlock := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if isLegacyMarker(line) {
+			inBlock = true
+			continue
+		}
 		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			inBlock = false
 			output = append(output, line)
 			continue
 		}
-
-		// Parse key
 		key := extractKey(trimmed)
-		if key == "" {
-			output = append(output, line)
+		if _, managed := params[key]; managed || (inBlock && key != "") {
+			removed = append(removed, key)
 			continue
 		}
-
-		// If this key is in template, update it
-		if value, found := params[key]; found {
-			output = append(output, fmt.Sprintf("%s = %s", key, value))
-			updated[key] = true
-		} else {
-			// Keep original line
-			output = append(output, line)
-		}
+		output = append(output, line)
 	}
 
-	// Append missing parameters
-	var missing []string
-	for key, value := range params {
-		if !updated[key] {
-			missing = append(missing, fmt.Sprintf("%s = %s", key, value))
+	// Removing a block leaves the blank lines around it adjacent; keep one.
+	collapsed := make([]string, 0, len(output))
+	for i, line := range output {
+		if strings.TrimSpace(line) == "" && i > 0 && strings.TrimSpace(output[i-1]) == "" {
+			continue
 		}
+		collapsed = append(collapsed, line)
 	}
 
-	if len(missing) > 0 {
-		output = append(output, "")
-		output = append(output, "# tcsss managed parameters")
-		output = append(output, missing...)
+	result := strings.TrimRight(strings.Join(collapsed, "\n"), "\n") + "\n"
+	if strings.TrimSpace(result) == "" {
+		result = ""
 	}
+	return result, removed, true
+}
 
-	result := strings.Join(output, "\n")
-	if !strings.HasSuffix(result, "\n") {
-		result += "\n"
+func isLegacyMarker(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	for _, marker := range legacyMarkers {
+		if trimmed == marker {
+			return true
+		}
 	}
+	return false
+}
 
-	return result
+// warnLegacyOverrides reports keys that sysctl.conf still sets: `sysctl --system` reads it
+// after sysctl.d, so its values win over the drop-in.
+func (sca *SysctlConfApplier) warnLegacyOverrides(params map[string]string) {
+	if sca.logger == nil || sca.legacyPath == "" || sca.legacyPath == sca.path {
+		return
+	}
+	data, err := os.ReadFile(sca.legacyPath)
+	if err != nil {
+		return
+	}
+	var overridden []string
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if key := extractKey(trimmed); key != "" {
+			if _, managed := params[key]; managed {
+				overridden = append(overridden, key)
+			}
+		}
+	}
+	if len(overridden) > 0 {
+		sort.Strings(overridden)
+		sca.logger.Warn("sysctl.conf overrides tcsss parameters",
+			slog.String("path", sca.legacyPath),
+			slog.String("keys", strings.Join(overridden, ",")))
+	}
 }
 
 // extractKey extracts the parameter key from a config line.
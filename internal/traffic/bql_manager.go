@@ -0,0 +1,119 @@
+package traffic
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	terr "tcsss/internal/errors"
+)
+
+const sysfsNetRoot = "/sys/class/net"
+
+// bqlLimit pairs a byte_queue_limits attribute with its desired value.
+type bqlLimit struct {
+	file  string
+	value string
+}
+
+// limits returns the attributes in write order: limit_max first so a raised limit_min never exceeds it.
+func (p bqlPolicy) limits() []bqlLimit {
+	return []bqlLimit{
+		{file: "limit_max", value: p.limitMax},
+		{file: "limit_min", value: p.limitMin},
+	}
+}
+
+// bqlQueueDirs lists the byte_queue_limits directories of every TX queue on the interface.
+func bqlQueueDirs(iface string) ([]string, error) {
+	pattern := filepath.Join(sysfsNetRoot, iface, "queues", "tx-*", "byte_queue_limits")
+	dirs, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("glob %s: %w", pattern, err)
+	}
+	return dirs, nil
+}
+
+// ensureBQL writes limit_max/limit_min on every TX queue, touching only values that differ.
+func (s *Shaper) ensureBQL(iface string, policy bqlPolicy) error {
+	if !policy.enabled() {
+		return nil
+	}
+
+	dirs, err := bqlQueueDirs(iface)
+	if err != nil {
+		return err
+	}
+	if len(dirs) == 0 {
+		if s.logger != nil {
+			s.logger.Debug("byte queue limits not exposed by driver", slog.String("interface", iface))
+		}
+		return nil
+	}
+
+	var errs terr.MultiError
+	changed := 0
+	for _, dir := range dirs {
+		for _, limit := range policy.limits() {
+			if limit.value == "" {
+				continue
+			}
+			path := filepath.Join(dir, limit.file)
+			wrote, err := writeSysfsIfChanged(path, limit.value, equalNumeric)
+			errs.Add(err)
+			changed += wrote
+			if wrote == 0 {
+				continue
+			}
+			// Some drivers clamp the limits without failing the write.
+			if got, err := readSysfsValue(path); err == nil && !equalNumeric(got, limit.value) {
+				errs.Add(fmt.Errorf("%s: driver kept %s, want %s", path, got, limit.value))
+			}
+		}
+	}
+
+	if changed > 0 && s.logger != nil {
+		s.logger.Debug("byte queue limits updated",
+			slog.String("interface", iface),
+			slog.Int("tx_queues", len(dirs)),
+			slog.Int("changed", changed),
+			slog.String("limit_min", policy.limitMin),
+			slog.String("limit_max", policy.limitMax))
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// bqlInSync reports whether every TX queue still carries the desired limits.
+// Interfaces without BQL support are always considered in sync.
+func bqlInSync(iface string, policy bqlPolicy) bool {
+	if !policy.enabled() {
+		return true
+	}
+	dirs, err := bqlQueueDirs(iface)
+	if err != nil {
+		return false
+	}
+	for _, dir := range dirs {
+		for _, limit := range policy.limits() {
+			if limit.value == "" {
+				continue
+			}
+			current, err := readSysfsValue(filepath.Join(dir, limit.file))
+			if err != nil || !equalNumeric(current, limit.value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func readSysfsValue(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
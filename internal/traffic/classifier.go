@@ -21,6 +21,24 @@ const (
 	classInternalVirtualSkip // Virtual interface skipped entirely (matches skip prefixes)
 )
 
+// String returns the profile-style name of the class for logging.
+func (c ifaceClass) String() string {
+	switch c {
+	case classLoopback:
+		return "loopback"
+	case classExternalPhysical:
+		return "external-physical"
+	case classExternalVirtual:
+		return "external-virtual"
+	case classInternalVirtual:
+		return "internal-virtual"
+	case classInternalVirtualSkip:
+		return "internal-virtual-skip"
+	default:
+		return "unknown"
+	}
+}
+
 const defaultExternalRefreshInterval = 30 * time.Second
 
 // InterfaceClassifier provides interface classification with routing awareness.
@@ -82,6 +82,13 @@ func (ic *InterfaceClassifier) RefreshExternalInterfaces() error {
 	return nil
 }
 
+// Invalidate forces the next RefreshExternalInterfaces call to re-read default routes.
+func (ic *InterfaceClassifier) Invalidate() {
+	ic.mu.Lock()
+	ic.lastRefresh = time.Time{}
+	ic.mu.Unlock()
+}
+
 // isExternalInterface checks if an interface handles external traffic.
 // An interface is external if:
 //  1. It has a default route
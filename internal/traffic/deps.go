@@ -18,9 +18,12 @@ type NetlinkClient interface {
 	LinkSetMTU(link netlink.Link, mtu int) error
 	LinkSetTxQLen(link netlink.Link, qlen int) error
 	RouteList(link netlink.Link, family int) ([]netlink.Route, error)
+	RouteListFiltered(family int, filter *netlink.Route, filterMask uint64) ([]netlink.Route, error)
+	RuleList(family int) ([]netlink.Rule, error)
 	RouteReplace(route *netlink.Route) error
 	LinkSubscribeWithOptions(ch chan netlink.LinkUpdate, done chan struct{}, opts netlink.LinkSubscribeOptions) error
 	AddrSubscribeWithOptions(ch chan netlink.AddrUpdate, done chan struct{}, opts netlink.AddrSubscribeOptions) error
+	RouteSubscribeWithOptions(ch chan netlink.RouteUpdate, done chan struct{}, opts netlink.RouteSubscribeOptions) error
 }
 
 // CommandExecutor abstracts command execution.
@@ -58,6 +61,14 @@ func (defaultNetlinkClient) RouteList(link netlink.Link, family int) ([]netlink.
 	return netlink.RouteList(link, family)
 }
 
+func (defaultNetlinkClient) RouteListFiltered(family int, filter *netlink.Route, filterMask uint64) ([]netlink.Route, error) {
+	return netlink.RouteListFiltered(family, filter, filterMask)
+}
+
+func (defaultNetlinkClient) RuleList(family int) ([]netlink.Rule, error) {
+	return netlink.RuleList(family)
+}
+
 func (defaultNetlinkClient) RouteReplace(route *netlink.Route) error {
 	return netlink.RouteReplace(route)
 }
@@ -70,6 +81,10 @@ func (defaultNetlinkClient) AddrSubscribeWithOptions(ch chan netlink.AddrUpdate,
 	return netlink.AddrSubscribeWithOptions(ch, done, opts)
 }
 
+func (defaultNetlinkClient) RouteSubscribeWithOptions(ch chan netlink.RouteUpdate, done chan struct{}, opts netlink.RouteSubscribeOptions) error {
+	return netlink.RouteSubscribeWithOptions(ch, done, opts)
+}
+
 type processExecutor struct{}
 
 func (processExecutor) Run(ctx context.Context, name string, args []string) (string, error) {
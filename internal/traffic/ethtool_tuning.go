@@ -0,0 +1,285 @@
+package traffic
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	terr "tcsss/internal/errors"
+)
+
+// ethtoolParam is a single name/value pair passed to ethtool -G, -L or -C.
+type ethtoolParam struct {
+	name  string
+	value string
+}
+
+const (
+	// ringValueMax requests the driver's pre-set maximum ring size.
+	ringValueMax = "max"
+	// channelValueCPUs matches the channel count to the number of online CPUs.
+	channelValueCPUs = "cpus"
+)
+
+// nicTuning describes ring, channel and interrupt coalescing targets for a physical NIC.
+type nicTuning struct {
+	name     string
+	rings    []ethtoolParam
+	channels string
+	coalesce []ethtoolParam
+}
+
+// nicTuningPresets maps the nicProfile template value to its ethtool targets; the keys match
+// config.NICProfiles, which validates the template.
+var nicTuningPresets = map[string]nicTuning{
+	"latency": {
+		name:     "latency",
+		rings:    []ethtoolParam{{"rx", "512"}, {"tx", "512"}},
+		channels: channelValueCPUs,
+		coalesce: []ethtoolParam{
+			{"adaptive-rx", "off"},
+			{"adaptive-tx", "off"},
+			{"rx-usecs", "8"},
+			{"tx-usecs", "16"},
+		},
+	},
+	"balanced": {
+		name:     "balanced",
+		rings:    []ethtoolParam{{"rx", "1024"}, {"tx", "1024"}},
+		channels: channelValueCPUs,
+		coalesce: []ethtoolParam{
+			{"adaptive-rx", "on"},
+			{"adaptive-tx", "on"},
+		},
+	},
+	"throughput": {
+		name:     "throughput",
+		rings:    []ethtoolParam{{"rx", ringValueMax}, {"tx", ringValueMax}},
+		channels: channelValueCPUs,
+		coalesce: []ethtoolParam{
+			{"adaptive-rx", "on"},
+			{"adaptive-tx", "on"},
+		},
+	},
+}
+
+var suppressNICTuning = []string{
+	"Operation not supported",
+	"cannot modify an unsupported parameter",
+	"no ring parameters changed",
+	"no channel parameters changed",
+	"no coalesce parameters changed",
+}
+
+func (t nicTuning) enabled() bool {
+	return t.name != ""
+}
+
+// signature summarises the preset together with the CPU count that drives channel sizing.
+func (t nicTuning) signature(cpus int) string {
+	if t.channels == channelValueCPUs {
+		return t.name + "/cpus=" + strconv.Itoa(cpus)
+	}
+	return t.name
+}
+
+// ensureNICTuning reads ring, channel and coalescing state back first and batches only mismatched values,
+// mirroring ensureOffloads.
+func (s *Shaper) ensureNICTuning(ctx context.Context, iface string, tuning nicTuning) {
+	if !tuning.enabled() {
+		return
+	}
+	s.ensureRings(ctx, iface, tuning.rings)
+	s.ensureChannels(ctx, iface, tuning.channels)
+	s.ensureCoalesce(ctx, iface, tuning.coalesce)
+}
+
+func (s *Shaper) ensureRings(ctx context.Context, iface string, rings []ethtoolParam) {
+	if len(rings) == 0 {
+		return
+	}
+	maximums, current := s.readEthtoolSections(ctx, "-g", iface)
+	if current == nil {
+		return
+	}
+
+	var batched []string
+	for _, ring := range rings {
+		limit, ok := parseEthtoolCount(maximums[ring.name])
+		if !ok || limit == 0 {
+			continue
+		}
+		desired := limit
+		if ring.value != ringValueMax {
+			value, err := strconv.Atoi(ring.value)
+			if err != nil || value <= 0 {
+				continue
+			}
+			desired = min(value, limit)
+		}
+		if cur, ok := parseEthtoolCount(current[ring.name]); ok && cur == desired {
+			continue
+		}
+		batched = append(batched, ring.name, strconv.Itoa(desired))
+	}
+
+	s.applyEthtoolBatch(ctx, "-G", iface, batched)
+}
+
+func (s *Shaper) ensureChannels(ctx context.Context, iface, channels string) {
+	if channels != channelValueCPUs {
+		return
+	}
+	maximums, current := s.readEthtoolSections(ctx, "-l", iface)
+	if current == nil {
+		return
+	}
+
+	cpus := len(s.onlineCPUs())
+	var batched []string
+	if limit, ok := parseEthtoolCount(maximums["combined"]); ok && limit > 0 {
+		desired := min(cpus, limit)
+		if cur, ok := parseEthtoolCount(current["combined"]); !ok || cur != desired {
+			batched = append(batched, "combined", strconv.Itoa(desired))
+		}
+	} else {
+		for _, name := range []string{"rx", "tx"} {
+			limit, ok := parseEthtoolCount(maximums[name])
+			if !ok || limit == 0 {
+				continue
+			}
+			desired := min(cpus, limit)
+			if cur, ok := parseEthtoolCount(current[name]); ok && cur == desired {
+				continue
+			}
+			batched = append(batched, name, strconv.Itoa(desired))
+		}
+	}
+
+	s.applyEthtoolBatch(ctx, "-L", iface, batched)
+}
+
+func (s *Shaper) ensureCoalesce(ctx context.Context, iface string, settings []ethtoolParam) {
+	if len(settings) == 0 {
+		return
+	}
+	current := s.readEthtoolCoalesce(ctx, iface)
+	if current == nil {
+		return
+	}
+
+	var batched []string
+	for _, setting := range settings {
+		cur, ok := current[setting.name]
+		if !ok || cur == "n/a" {
+			// Driver does not expose this knob.
+			continue
+		}
+		if strings.EqualFold(cur, setting.value) {
+			continue
+		}
+		batched = append(batched, setting.name, setting.value)
+	}
+
+	s.applyEthtoolBatch(ctx, "-C", iface, batched)
+}
+
+func (s *Shaper) applyEthtoolBatch(ctx context.Context, flag, iface string, batched []string) {
+	if len(batched) == 0 {
+		return
+	}
+	args := append([]string{flag, iface}, batched...)
+	if err := s.runOptional(ctx, "ethtool", args, suppressNICTuning); err != nil {
+		s.logOptional("batched ethtool tuning skipped", iface, err, terr.ErrorContext{
+			Command: "ethtool " + flag,
+			Extra: map[string]any{
+				"settings": batched,
+			},
+		})
+	}
+}
+
+// readEthtoolSections parses the "Pre-set maximums" and "Current hardware settings" blocks printed
+// by ethtool -g and -l. Keys are lower-cased with spaces replaced by dashes (e.g. "rx-jumbo").
+func (s *Shaper) readEthtoolSections(ctx context.Context, flag, iface string) (map[string]string, map[string]string) {
+	out, err := s.runGetOutput(ctx, "ethtool", flag, iface)
+	if err != nil || out == "" {
+		return nil, nil
+	}
+
+	maximums := map[string]string{}
+	current := map[string]string{}
+	var section map[string]string
+	for _, ln := range strings.Split(out, "\n") {
+		ln = strings.TrimSpace(ln)
+		if ln == "" {
+			continue
+		}
+		lower := strings.ToLower(ln)
+		switch {
+		case strings.HasPrefix(lower, "pre-set maximums"):
+			section = maximums
+			continue
+		case strings.HasPrefix(lower, "current hardware settings"):
+			section = current
+			continue
+		}
+		if section == nil {
+			continue
+		}
+		parts := strings.SplitN(ln, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(parts[0])), " ", "-")
+		section[key] = strings.TrimSpace(parts[1])
+	}
+
+	if len(current) == 0 {
+		return nil, nil
+	}
+	return maximums, current
+}
+
+// readEthtoolCoalesce parses ethtool -c output into ethtool -C parameter names.
+func (s *Shaper) readEthtoolCoalesce(ctx context.Context, iface string) map[string]string {
+	out, err := s.runGetOutput(ctx, "ethtool", "-c", iface)
+	if err != nil || out == "" {
+		return nil
+	}
+
+	settings := map[string]string{}
+	for _, ln := range strings.Split(out, "\n") {
+		ln = strings.TrimSpace(ln)
+		lower := strings.ToLower(ln)
+		if ln == "" || strings.HasPrefix(lower, "coalesce parameters for ") {
+			continue
+		}
+		// "Adaptive RX: on  TX: off" carries two settings on one line.
+		if strings.HasPrefix(lower, "adaptive ") {
+			fields := strings.Fields(strings.ReplaceAll(lower, ":", " "))
+			for i := 1; i+1 < len(fields); i += 2 {
+				settings["adaptive-"+fields[i]] = fields[i+1]
+			}
+			continue
+		}
+		parts := strings.SplitN(ln, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		settings[strings.ToLower(strings.TrimSpace(parts[0]))] = strings.TrimSpace(parts[1])
+	}
+
+	if len(settings) == 0 {
+		return nil
+	}
+	return settings
+}
+
+func parseEthtoolCount(value string) (int, bool) {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,244 @@
+package traffic
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+// Interface health states reported in logs and InterfaceStatus.State.
+const (
+	HealthHealthy  = "healthy"
+	HealthBackoff  = "backoff"
+	HealthHoldDown = "hold-down"
+	HealthDegraded = "degraded"
+)
+
+// ifaceHealth tracks apply failures and oper state flaps for a single interface.
+type ifaceHealth struct {
+	failures    int
+	nextAttempt time.Time
+	holdUntil   time.Time
+	degraded    bool
+	retry       bool
+	lastError   string
+	operState   netlink.LinkOperState
+	operSeen    bool
+	flaps       []time.Time
+}
+
+// healthTracker rate-limits reapplication of failing or flapping interfaces.
+//   - Each consecutive failure doubles the retry delay up to BackoffMax.
+//   - FlapThreshold oper state changes within FlapWindow place the interface in hold-down.
+//   - DegradedAfter consecutive failures mark the interface degraded until the next success.
+type healthTracker struct {
+	mu       sync.Mutex
+	logger   *slog.Logger
+	settings WatcherSettings
+	now      func() time.Time
+	entries  map[string]*ifaceHealth
+}
+
+func newHealthTracker(logger *slog.Logger, settings WatcherSettings) *healthTracker {
+	return &healthTracker{
+		logger:   logger,
+		settings: settings,
+		now:      time.Now,
+		entries:  make(map[string]*ifaceHealth),
+	}
+}
+
+func (h *healthTracker) entryLocked(name string) *ifaceHealth {
+	entry, ok := h.entries[name]
+	if !ok {
+		entry = &ifaceHealth{}
+		h.entries[name] = entry
+	}
+	return entry
+}
+
+// allow reports whether the interface may be configured now. Deferred interfaces are
+// remembered and returned by due once their backoff or hold-down expires.
+func (h *healthTracker) allow(name string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entry, ok := h.entries[name]
+	if !ok {
+		return true
+	}
+	now := h.now()
+	if now.Before(entry.nextAttempt) || now.Before(entry.holdUntil) {
+		entry.retry = true
+		return false
+	}
+	return true
+}
+
+// record updates the interface state after an apply attempt.
+func (h *healthTracker) record(name string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err == nil {
+		entry, ok := h.entries[name]
+		if !ok || entry.failures == 0 {
+			return
+		}
+		if entry.degraded && h.logger != nil {
+			h.logger.Info("interface recovered",
+				slog.String("interface", name),
+				slog.Int("failures", entry.failures))
+		}
+		entry.failures = 0
+		entry.nextAttempt = time.Time{}
+		entry.degraded = false
+		entry.retry = false
+		entry.lastError = ""
+		return
+	}
+
+	entry := h.entryLocked(name)
+	entry.failures++
+	entry.lastError = err.Error()
+	entry.retry = true
+
+	delay := h.settings.BackoffBase << min(entry.failures-1, 16)
+	if delay <= 0 || delay > h.settings.BackoffMax {
+		delay = h.settings.BackoffMax
+	}
+	entry.nextAttempt = h.now().Add(delay)
+
+	becameDegraded := !entry.degraded && entry.failures >= h.settings.DegradedAfter
+	if becameDegraded {
+		entry.degraded = true
+	}
+	if h.logger == nil {
+		return
+	}
+	if becameDegraded {
+		h.logger.Warn("interface degraded",
+			slog.String("interface", name),
+			slog.Int("failures", entry.failures),
+			slog.String("last_error", entry.lastError),
+			slog.Duration("retry_in", delay))
+		return
+	}
+	h.logger.Debug("interface apply backoff",
+		slog.String("interface", name),
+		slog.Int("failures", entry.failures),
+		slog.Duration("retry_in", delay))
+}
+
+// observeLink counts oper state transitions and starts a hold-down when the interface flaps.
+func (h *healthTracker) observeLink(update netlink.LinkUpdate) {
+	attrs := update.Attrs()
+	if attrs == nil || attrs.Name == "" {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entry := h.entryLocked(attrs.Name)
+	previous, seen := entry.operState, entry.operSeen
+	entry.operState, entry.operSeen = attrs.OperState, true
+	if !seen || previous == attrs.OperState {
+		return
+	}
+
+	now := h.now()
+	cutoff := now.Add(-h.settings.FlapWindow)
+	flaps := entry.flaps[:0]
+	for _, at := range entry.flaps {
+		if at.After(cutoff) {
+			flaps = append(flaps, at)
+		}
+	}
+	entry.flaps = append(flaps, now)
+
+	if len(entry.flaps) < h.settings.FlapThreshold || now.Before(entry.holdUntil) {
+		return
+	}
+	entry.holdUntil = now.Add(h.settings.HoldDown)
+	entry.retry = true
+	if h.logger != nil {
+		h.logger.Warn("interface flapping, holding down",
+			slog.String("interface", attrs.Name),
+			slog.Int("transitions", len(entry.flaps)),
+			slog.Duration("window", h.settings.FlapWindow),
+			slog.Duration("hold_down", h.settings.HoldDown))
+	}
+}
+
+// due returns deferred interfaces whose backoff and hold-down have both expired.
+func (h *healthTracker) due() map[string]struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	var names map[string]struct{}
+	for name, entry := range h.entries {
+		if !entry.retry || now.Before(entry.nextAttempt) || now.Before(entry.holdUntil) {
+			continue
+		}
+		entry.retry = false
+		if names == nil {
+			names = map[string]struct{}{}
+		}
+		names[name] = struct{}{}
+	}
+	return names
+}
+
+// forget drops state for interfaces that no longer exist.
+func (h *healthTracker) forget(current map[string]struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for name := range h.entries {
+		if _, ok := current[name]; !ok {
+			delete(h.entries, name)
+		}
+	}
+}
+
+// snapshot returns the health of every tracked interface sorted by name.
+func (h *healthTracker) snapshot() []InterfaceStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	result := make([]InterfaceStatus, 0, len(h.entries))
+	for name, entry := range h.entries {
+		state := HealthHealthy
+		switch {
+		case now.Before(entry.holdUntil):
+			state = HealthHoldDown
+		case entry.degraded:
+			state = HealthDegraded
+		case entry.failures > 0:
+			state = HealthBackoff
+		}
+		item := InterfaceStatus{
+			Name:      name,
+			State:     state,
+			Failures:  entry.failures,
+			LastError: entry.lastError,
+		}
+		if now.Before(entry.nextAttempt) {
+			item.NextAttempt = entry.nextAttempt
+		}
+		if now.Before(entry.holdUntil) {
+			item.HoldUntil = entry.holdUntil
+		}
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
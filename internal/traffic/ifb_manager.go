@@ -138,20 +138,7 @@ func (s *Shaper) cleanupSkippedVirtualInterfaces(ctx context.Context, links []ne
 			continue
 		}
 
-		// Remove root qdisc (ignore errors, interface might not have one)
-		if err := s.runQuiet(ctx, "tc", "qdisc", "del", "dev", name, "root"); err != nil {
-			s.logOptional("skip virtual qdisc root cleanup", name, err, terr.ErrorContext{Command: "tc qdisc del root"})
-		}
-		// Remove ingress qdisc (ignore errors)
-		if err := s.runQuiet(ctx, "tc", "qdisc", "del", "dev", name, "handle", IngressHandle, "ingress"); err != nil {
-			s.logOptional("skip virtual ingress qdisc cleanup", name, err, terr.ErrorContext{Command: "tc qdisc del ingress"})
-		}
-
-		// Try to remove any associated ifb interface for this interface
-		ifbName := truncateIfb(IfbPrefix + name)
-		if err := s.runQuiet(ctx, "ip", "link", "del", ifbName); err != nil {
-			s.logOptional("skip virtual ifb cleanup", ifbName, err, terr.ErrorContext{Command: "ip link del"})
-		}
+		s.teardownShaping(ctx, name)
 
 		if s.logger != nil {
 			s.logger.Debug("cleaned up qdisc from skipped virtual interface", slog.String("interface", name))
@@ -161,6 +148,25 @@ func (s *Shaper) cleanupSkippedVirtualInterfaces(ctx context.Context, links []ne
 	return nil
 }
 
+// teardownShaping removes the root and ingress qdiscs of an interface together with its ifb mirror.
+// All failures are optional: the interface may never have been shaped.
+func (s *Shaper) teardownShaping(ctx context.Context, name string) {
+	// Remove root qdisc (ignore errors, interface might not have one)
+	if err := s.runQuiet(ctx, "tc", "qdisc", "del", "dev", name, "root"); err != nil {
+		s.logOptional("skip qdisc root cleanup", name, err, terr.ErrorContext{Command: "tc qdisc del root"})
+	}
+	// Remove ingress qdisc (ignore errors)
+	if err := s.runQuiet(ctx, "tc", "qdisc", "del", "dev", name, "handle", IngressHandle, "ingress"); err != nil {
+		s.logOptional("skip ingress qdisc cleanup", name, err, terr.ErrorContext{Command: "tc qdisc del ingress"})
+	}
+
+	// Try to remove any associated ifb interface for this interface
+	ifbName := truncateIfb(IfbPrefix + name)
+	if err := s.runQuiet(ctx, "ip", "link", "del", ifbName); err != nil {
+		s.logOptional("skip ifb cleanup", ifbName, err, terr.ErrorContext{Command: "ip link del"})
+	}
+}
+
 func truncateIfb(name string) string {
 	if len(name) <= 15 {
 		return name
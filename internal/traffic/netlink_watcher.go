@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
 
 	terr "tcsss/internal/errors"
 )
@@ -30,20 +31,94 @@ func (s *Shaper) Watch(ctx context.Context) (err error) {
 		}
 	}()
 
+	failures := 0
+	resync := false
+	for {
+		started := time.Now()
+		watchErr := s.watchSubscriptions(ctx, resync)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// A subscription that survived long enough is not part of a failure streak.
+		if time.Since(started) >= subscriptionStableAfter {
+			failures = 0
+		}
+		failures++
+		if failures > s.maxResubscribes {
+			return terr.New(
+				terr.CategoryCritical,
+				fmt.Errorf("netlink subscriptions failed %d times in a row: %w", failures, watchErr),
+				terr.ErrorContext{Operation: "netlink_resubscribe"},
+			)
+		}
+
+		delay := min(s.backoffBase<<(failures-1), maxResubscribeDelay)
+		count := s.resubscriptions.Add(1)
+		if s.logger != nil {
+			s.logger.Warn("netlink subscription lost, resubscribing",
+				slog.String("error", watchErr.Error()),
+				slog.Int("attempt", failures),
+				slog.Int64("resubscriptions", count),
+				slog.Duration("retry_in", delay))
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		resync = true
+	}
+}
+
+const (
+	// subscriptionStableAfter resets the consecutive failure count once a subscription has run this long.
+	subscriptionStableAfter = time.Minute
+	// maxResubscribeDelay caps the wait between resubscription attempts.
+	maxResubscribeDelay = 30 * time.Second
+)
+
+// watchSubscriptions subscribes to netlink and runs the event loop until a channel closes.
+// After a resubscription every interface is reapplied to cover events missed while the socket was down.
+func (s *Shaper) watchSubscriptions(ctx context.Context, resync bool) error {
 	subs, err := s.setupNetlinkSubscriptions()
 	if err != nil {
 		return err
 	}
 	defer subs.Close()
 
+	if resync {
+		s.resyncAll(ctx)
+	}
+
 	return s.watchLoop(ctx, subs)
 }
 
+func (s *Shaper) resyncAll(ctx context.Context) {
+	ctxApply, cancel := context.WithTimeout(ctx, s.applyTimeout)
+	defer cancel()
+
+	s.classifier.Invalidate()
+	s.reconcileRoutes(ctxApply)
+	if err := s.applyInterfaces(ctxApply, nil); err != nil && !errors.Is(err, context.Canceled) {
+		s.handleCategorizedError("resync after resubscribe failed", "", err, terr.CategoryRecoverable)
+	} else if s.logger != nil {
+		s.logger.Info("netlink resubscribed, interfaces resynchronised",
+			slog.Int64("resubscriptions", s.resubscriptions.Load()))
+	}
+	s.publishStatus()
+}
+
 type netlinkSubscriptions struct {
 	links     chan netlink.LinkUpdate
 	addrs     chan netlink.AddrUpdate
+	routes    chan netlink.RouteUpdate
 	linkDone  chan struct{}
 	addrDone  chan struct{}
+	routeDone chan struct{}
 	closeOnce sync.Once
 }
 
@@ -51,15 +126,18 @@ func (s *netlinkSubscriptions) Close() {
 	s.closeOnce.Do(func() {
 		close(s.linkDone)
 		close(s.addrDone)
+		close(s.routeDone)
 	})
 }
 
 func (s *Shaper) setupNetlinkSubscriptions() (*netlinkSubscriptions, error) {
 	subs := &netlinkSubscriptions{
-		links:    make(chan netlink.LinkUpdate, 32),
-		addrs:    make(chan netlink.AddrUpdate, 32),
-		linkDone: make(chan struct{}),
-		addrDone: make(chan struct{}),
+		links:     make(chan netlink.LinkUpdate, 32),
+		addrs:     make(chan netlink.AddrUpdate, 32),
+		routes:    make(chan netlink.RouteUpdate, 32),
+		linkDone:  make(chan struct{}),
+		addrDone:  make(chan struct{}),
+		routeDone: make(chan struct{}),
 	}
 
 	if err := s.netlink.LinkSubscribeWithOptions(subs.links, subs.linkDone, netlink.LinkSubscribeOptions{ListExisting: false}); err != nil {
@@ -78,6 +156,14 @@ func (s *Shaper) setupNetlinkSubscriptions() (*netlinkSubscriptions, error) {
 			terr.ErrorContext{Operation: "netlink_addr_subscribe"},
 		)
 	}
+	if err := s.netlink.RouteSubscribeWithOptions(subs.routes, subs.routeDone, netlink.RouteSubscribeOptions{ListExisting: false}); err != nil {
+		subs.Close()
+		return nil, terr.New(
+			terr.CategoryCritical,
+			fmt.Errorf("subscribe route: %w", err),
+			terr.ErrorContext{Operation: "netlink_route_subscribe"},
+		)
+	}
 
 	return subs, nil
 }
@@ -88,6 +174,13 @@ func (s *Shaper) watchLoop(ctx context.Context, subs *netlinkSubscriptions) erro
 	defer applyTicker.Stop()
 	defer cleanupTicker.Stop()
 
+	var scheduleTick <-chan time.Time
+	if len(s.schedule.Entries) > 0 {
+		scheduleTicker := time.NewTicker(scheduleCheckInterval)
+		defer scheduleTicker.Stop()
+		scheduleTick = scheduleTicker.C
+	}
+
 	pending := newPendingChanges(s.netlink)
 
 	for {
@@ -98,16 +191,39 @@ func (s *Shaper) watchLoop(ctx context.Context, subs *netlinkSubscriptions) erro
 			if !ok {
 				return errors.New("link subscription closed")
 			}
+			// Flaps only matter for interfaces the shaper configures; ifb mirrors, skipped
+			// and unclassified links are not tracked.
+			if attrs := update.Attrs(); attrs != nil && s.shaped(attrs.Name) {
+				s.health.observeLink(update)
+			}
 			pending.AddLink(update)
+			if s.routeOptimizer.TracksLink(update) {
+				pending.MarkRouteTuning()
+			}
 		case update, ok := <-subs.addrs:
 			if !ok {
 				return errors.New("addr subscription closed")
 			}
 			pending.AddAddr(update)
+		case update, ok := <-subs.routes:
+			if !ok {
+				return errors.New("route subscription closed")
+			}
+			pending.AddRoute(update)
+			if s.routeOptimizer.Tracks(update) {
+				pending.MarkRouteTuning()
+			}
 		case <-applyTicker.C:
+			if s.cpuTopologyChanged() {
+				pending.MarkAll()
+			}
+			pending.addNames(s.health.due())
 			if err := s.applyPending(ctx, pending); err != nil && !errors.Is(err, context.Canceled) {
 				s.handleCategorizedError("reapply failed", "", err, terr.CategoryRecoverable)
 			}
+			s.publishStatus()
+		case <-scheduleTick:
+			pending.addNames(s.applySchedule(ctx))
 		case <-cleanupTicker.C:
 			if err := s.cleanupStaleSignatures(); err != nil {
 				s.handleCategorizedError("cleanup stale signatures failed", "", err, terr.CategoryRecoverable)
@@ -117,10 +233,12 @@ func (s *Shaper) watchLoop(ctx context.Context, subs *netlinkSubscriptions) erro
 }
 
 type pendingChanges struct {
-	mu      sync.Mutex
-	all     bool
-	names   map[string]struct{}
-	netlink NetlinkClient
+	mu            sync.Mutex
+	all           bool
+	names         map[string]struct{}
+	routesChanged bool
+	routesToTune  bool
+	netlink       NetlinkClient
 }
 
 func newPendingChanges(netlinkClient NetlinkClient) *pendingChanges {
@@ -171,6 +289,71 @@ func (p *pendingChanges) AddAddr(update netlink.AddrUpdate) {
 	p.markAllLocked()
 }
 
+// AddRoute records a default route change in the main table, which may move interfaces
+// between the external and internal classes.
+func (p *pendingChanges) AddRoute(update netlink.RouteUpdate) {
+	if !isDefaultRoute(update.Route) {
+		return
+	}
+	if update.Table != 0 && update.Table != unix.RT_TABLE_MAIN {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.routesChanged = true
+}
+
+// takeRouteChange reports and resets the pending default route change flag.
+func (p *pendingChanges) takeRouteChange() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	changed := p.routesChanged
+	p.routesChanged = false
+	return changed
+}
+
+// MarkRouteTuning schedules route reconciliation on the next apply tick, after a route event
+// that may have added an untuned route or replaced a tuned one.
+func (p *pendingChanges) MarkRouteTuning() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.routesToTune = true
+}
+
+// takeRouteTuning reports and resets the pending route reconciliation flag.
+func (p *pendingChanges) takeRouteTuning() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tune := p.routesToTune
+	p.routesToTune = false
+	return tune
+}
+
+// MarkAll schedules every interface for reapplication.
+func (p *pendingChanges) MarkAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.markAllLocked()
+}
+
+func (p *pendingChanges) addNames(names map[string]struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.all {
+		return
+	}
+	for name := range names {
+		p.addNameLocked(name)
+	}
+}
+
 func (p *pendingChanges) addNameLocked(name string) {
 	if p.names == nil {
 		p.names = map[string]struct{}{}
@@ -209,6 +392,15 @@ func (p *pendingChanges) clear() {
 }
 
 func (s *Shaper) applyPending(ctx context.Context, pending *pendingChanges) error {
+	if pending.takeRouteTuning() {
+		ctxRoutes, cancel := context.WithTimeout(ctx, s.applyTimeout)
+		s.reconcileRoutes(ctxRoutes)
+		cancel()
+	}
+	if pending.takeRouteChange() {
+		pending.addNames(s.reclassifyInterfaces(ctx))
+	}
+
 	applyAll, names := pending.snapshot()
 	if !applyAll && len(names) == 0 {
 		return nil
@@ -223,3 +415,14 @@ func (s *Shaper) applyPending(ctx context.Context, pending *pendingChanges) erro
 	}
 	return s.applyInterfaces(ctxApply, names)
 }
+
+// reconcileRoutes re-tunes routes added or replaced since the last pass.
+func (s *Shaper) reconcileRoutes(ctx context.Context) {
+	if err := s.routeOptimizer.Reconcile(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		s.handleCategorizedError("route reconciliation failed", "", terr.New(
+			terr.CategoryRecoverable,
+			fmt.Errorf("reconcile routes: %w", err),
+			terr.ErrorContext{Operation: "reconcile_routes"},
+		), terr.CategoryRecoverable)
+	}
+}
@@ -11,11 +11,20 @@ type offloadSetting struct {
 	state   string
 }
 
+// bqlPolicy holds the byte queue limits written to each TX queue; an empty limitMax disables BQL tuning.
+type bqlPolicy struct {
+	limitMin string
+	limitMax string
+}
+
 type shapingProfile struct {
 	queueLength string
 	rootQdisc   []string
 	ifbQdisc    []string
 	offloads    []offloadSetting
+	bql         bqlPolicy
+	nic         nicTuning
+	steering    bool
 	mtuOverride string
 }
 
@@ -99,12 +108,16 @@ func newProfileSet(cfg ProfileSettings) profileSet {
 			rootQdisc:   externalRootQdisc,
 			ifbQdisc:    externalIfbQdisc,
 			offloads:    offloadsWithGro("off"),
+			bql:         newBQLPolicy(cfg.VirtualBQL),
 		},
 		externalPhysical: shapingProfile{
 			queueLength: queue,
 			rootQdisc:   externalRootQdisc,
 			ifbQdisc:    externalIfbQdisc,
 			offloads:    offloadsWithGro("on"),
+			bql:         newBQLPolicy(cfg.PhysicalBQL),
+			nic:         nicTuningPresets[strings.ToLower(strings.TrimSpace(cfg.NICProfile))],
+			steering:    true,
 		},
 		loopback: shapingProfile{
 			queueLength: loopbackQueue,
@@ -124,6 +137,27 @@ func offloadsWithGro(state string) []offloadSetting {
 	return result
 }
 
+func newBQLPolicy(cfg BQLSettings) bqlPolicy {
+	if cfg.LimitMax <= 0 {
+		return bqlPolicy{}
+	}
+	limitMin := cfg.LimitMin
+	if limitMin < 0 {
+		limitMin = 0
+	}
+	if limitMin > cfg.LimitMax {
+		limitMin = cfg.LimitMax
+	}
+	return bqlPolicy{
+		limitMin: strconv.Itoa(limitMin),
+		limitMax: strconv.Itoa(cfg.LimitMax),
+	}
+}
+
+func (p bqlPolicy) enabled() bool {
+	return p.limitMax != ""
+}
+
 func renderDuration(d time.Duration) string {
 	if d <= 0 {
 		return "0s"
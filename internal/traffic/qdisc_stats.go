@@ -0,0 +1,288 @@
+package traffic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	terr "tcsss/internal/errors"
+)
+
+// QdiscStats is the typed view of a single qdisc reported by `tc -s -j qdisc show`.
+type QdiscStats struct {
+	Interface  string     `json:"interface"`
+	Kind       string     `json:"kind"`
+	Handle     string     `json:"handle"`
+	Parent     string     `json:"parent,omitempty"`
+	Root       bool       `json:"root"`
+	Bytes      uint64     `json:"bytes"`
+	Packets    uint64     `json:"packets"`
+	Drops      uint64     `json:"drops"`
+	Overlimits uint64     `json:"overlimits"`
+	Requeues   uint64     `json:"requeues"`
+	Backlog    uint64     `json:"backlog"`
+	Qlen       uint64     `json:"qlen"`
+	Cake       *CakeStats `json:"cake,omitempty"`
+}
+
+// CakeStats carries the CAKE specific options and extended statistics.
+type CakeStats struct {
+	Diffserv string `json:"diffserv"`
+	// Bandwidth is the configured shaper rate in bytes per second; zero means unlimited.
+	Bandwidth        uint64         `json:"bandwidth"`
+	CapacityEstimate uint64         `json:"capacity_estimate"`
+	MemoryUsed       uint64         `json:"memory_used"`
+	MemoryLimit      uint64         `json:"memory_limit"`
+	Tins             []CakeTinStats `json:"tins"`
+}
+
+// CakeTinStats holds the per-tin counters of a CAKE qdisc.
+type CakeTinStats struct {
+	Name string `json:"name"`
+	// ThresholdRate is the tin's bandwidth threshold in bytes per second.
+	ThresholdRate     uint64        `json:"threshold_rate"`
+	SentBytes         uint64        `json:"sent_bytes"`
+	SentPackets       uint64        `json:"sent_packets"`
+	BacklogBytes      uint64        `json:"backlog_bytes"`
+	Target            time.Duration `json:"target"`
+	Interval          time.Duration `json:"interval"`
+	PeakDelay         time.Duration `json:"peak_delay"`
+	AvgDelay          time.Duration `json:"avg_delay"`
+	BaseDelay         time.Duration `json:"base_delay"`
+	Drops             uint64        `json:"drops"`
+	ECNMarks          uint64        `json:"ecn_marks"`
+	AckDrops          uint64        `json:"ack_drops"`
+	SparseFlows       uint64        `json:"sparse_flows"`
+	BulkFlows         uint64        `json:"bulk_flows"`
+	UnresponsiveFlows uint64        `json:"unresponsive_flows"`
+	MaxPacketLen      uint64        `json:"max_pkt_len"`
+}
+
+// QueueStats groups the egress root qdisc of an interface with the root qdisc of its IFB mirror.
+type QueueStats struct {
+	Interface   string      `json:"interface"`
+	CollectedAt time.Time   `json:"collected_at"`
+	Egress      *QdiscStats `json:"egress,omitempty"`
+	IfbName     string      `json:"ifb,omitempty"`
+	Ingress     *QdiscStats `json:"ingress,omitempty"`
+}
+
+// cakeTinNames lists tin names in kernel order for the diffserv presets with named tins.
+var cakeTinNames = map[string][]string{
+	"diffserv3": {"bulk", "besteffort", "voice"},
+	"diffserv4": {"bulk", "besteffort", "video", "voice"},
+}
+
+// rawQdisc mirrors the JSON emitted by iproute2; CAKE xstats are flattened into the qdisc object.
+type rawQdisc struct {
+	Kind             string                     `json:"kind"`
+	Handle           string                     `json:"handle"`
+	Parent           string                     `json:"parent"`
+	Root             bool                       `json:"root"`
+	Options          map[string]json.RawMessage `json:"options"`
+	Bytes            uint64                     `json:"bytes"`
+	Packets          uint64                     `json:"packets"`
+	Drops            uint64                     `json:"drops"`
+	Overlimits       uint64                     `json:"overlimits"`
+	Requeues         uint64                     `json:"requeues"`
+	Backlog          uint64                     `json:"backlog"`
+	Qlen             uint64                     `json:"qlen"`
+	MemoryUsed       uint64                     `json:"memory_used"`
+	MemoryLimit      uint64                     `json:"memory_limit"`
+	CapacityEstimate uint64                     `json:"capacity_estimate"`
+	Tins             []rawCakeTin               `json:"tins"`
+}
+
+type rawCakeTin struct {
+	ThresholdRate     uint64 `json:"threshold_rate"`
+	SentBytes         uint64 `json:"sent_bytes"`
+	SentPackets       uint64 `json:"sent_packets"`
+	BacklogBytes      uint64 `json:"backlog_bytes"`
+	TargetUs          uint64 `json:"target_us"`
+	IntervalUs        uint64 `json:"interval_us"`
+	PeakDelayUs       uint64 `json:"peak_delay_us"`
+	AvgDelayUs        uint64 `json:"avg_delay_us"`
+	BaseDelayUs       uint64 `json:"base_delay_us"`
+	Drops             uint64 `json:"drops"`
+	ECNMark           uint64 `json:"ecn_mark"`
+	AckDrops          uint64 `json:"ack_drops"`
+	SparseFlows       uint64 `json:"sparse_flows"`
+	BulkFlows         uint64 `json:"bulk_flows"`
+	UnresponsiveFlows uint64 `json:"unresponsive_flows"`
+	MaxPktLen         uint64 `json:"max_pkt_len"`
+}
+
+// ParseQdiscStats converts `tc -s -j qdisc show dev <iface>` output into typed statistics.
+func ParseQdiscStats(iface string, data []byte) ([]QdiscStats, error) {
+	var raw []rawQdisc
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("decode qdisc stats for %s: %w", iface, err)
+	}
+
+	result := make([]QdiscStats, 0, len(raw))
+	for _, q := range raw {
+		stats := QdiscStats{
+			Interface:  iface,
+			Kind:       q.Kind,
+			Handle:     q.Handle,
+			Parent:     q.Parent,
+			Root:       q.Root,
+			Bytes:      q.Bytes,
+			Packets:    q.Packets,
+			Drops:      q.Drops,
+			Overlimits: q.Overlimits,
+			Requeues:   q.Requeues,
+			Backlog:    q.Backlog,
+			Qlen:       q.Qlen,
+		}
+		if q.Kind == "cake" {
+			stats.Cake = parseCakeStats(q)
+		}
+		result = append(result, stats)
+	}
+	return result, nil
+}
+
+func parseCakeStats(q rawQdisc) *CakeStats {
+	cake := &CakeStats{
+		Diffserv:         rawOptionString(q.Options, "diffserv"),
+		CapacityEstimate: q.CapacityEstimate,
+		MemoryUsed:       q.MemoryUsed,
+		MemoryLimit:      q.MemoryLimit,
+		Tins:             make([]CakeTinStats, 0, len(q.Tins)),
+	}
+	if bandwidth, err := strconv.ParseUint(rawOptionString(q.Options, "bandwidth"), 10, 64); err == nil {
+		cake.Bandwidth = bandwidth
+	}
+
+	names := cakeTinNames[cake.Diffserv]
+	for i, tin := range q.Tins {
+		name := "tin" + strconv.Itoa(i)
+		if i < len(names) {
+			name = names[i]
+		}
+		cake.Tins = append(cake.Tins, CakeTinStats{
+			Name:              name,
+			ThresholdRate:     tin.ThresholdRate,
+			SentBytes:         tin.SentBytes,
+			SentPackets:       tin.SentPackets,
+			BacklogBytes:      tin.BacklogBytes,
+			Target:            time.Duration(tin.TargetUs) * time.Microsecond,
+			Interval:          time.Duration(tin.IntervalUs) * time.Microsecond,
+			PeakDelay:         time.Duration(tin.PeakDelayUs) * time.Microsecond,
+			AvgDelay:          time.Duration(tin.AvgDelayUs) * time.Microsecond,
+			BaseDelay:         time.Duration(tin.BaseDelayUs) * time.Microsecond,
+			Drops:             tin.Drops,
+			ECNMarks:          tin.ECNMark,
+			AckDrops:          tin.AckDrops,
+			SparseFlows:       tin.SparseFlows,
+			BulkFlows:         tin.BulkFlows,
+			UnresponsiveFlows: tin.UnresponsiveFlows,
+			MaxPacketLen:      tin.MaxPktLen,
+		})
+	}
+	return cake
+}
+
+// rawOptionString returns a qdisc option as text whether iproute2 printed it as a string or a number.
+func rawOptionString(options map[string]json.RawMessage, key string) string {
+	value, ok := options[key]
+	if !ok {
+		return ""
+	}
+	var text string
+	if err := json.Unmarshal(value, &text); err == nil {
+		return text
+	}
+	return strings.TrimSpace(string(value))
+}
+
+// Tin returns the tin with the given name, or nil when the qdisc has no such tin.
+func (c *CakeStats) Tin(name string) *CakeTinStats {
+	if c == nil {
+		return nil
+	}
+	for i := range c.Tins {
+		if c.Tins[i].Name == name {
+			return &c.Tins[i]
+		}
+	}
+	return nil
+}
+
+// QdiscStats reads every qdisc attached to the interface.
+func (s *Shaper) QdiscStats(ctx context.Context, iface string) ([]QdiscStats, error) {
+	out, err := s.runGetOutput(ctx, "tc", "-s", "-j", "qdisc", "show", "dev", iface)
+	if err != nil {
+		return nil, wrapInterfaceError(
+			fmt.Errorf("read qdisc stats: %w", err),
+			iface,
+			"qdisc_stats",
+			terr.ErrorContext{Command: "tc -s -j qdisc show"},
+		)
+	}
+	// Output is combined with stderr; skip any warning tc printed before the JSON array.
+	if idx := strings.Index(out, "["); idx > 0 {
+		out = out[idx:]
+	}
+	return ParseQdiscStats(iface, []byte(out))
+}
+
+// QueueStats collects the root qdisc of the interface and of its IFB mirror when one exists.
+func (s *Shaper) QueueStats(ctx context.Context, iface string) (QueueStats, error) {
+	result := QueueStats{Interface: iface, CollectedAt: time.Now()}
+
+	egress, err := s.QdiscStats(ctx, iface)
+	if err != nil {
+		return result, err
+	}
+	result.Egress = rootQdisc(egress)
+
+	ifbName := truncateIfb(IfbPrefix + iface)
+	if _, err := s.netlink.LinkByName(ifbName); err == nil {
+		ingress, err := s.QdiscStats(ctx, ifbName)
+		if err != nil {
+			return result, err
+		}
+		result.IfbName = ifbName
+		result.Ingress = rootQdisc(ingress)
+	}
+	return result, nil
+}
+
+// CollectQueueStats gathers queue statistics for every interface the shaper has configured.
+// Interfaces that fail are skipped and reported through the returned error.
+func (s *Shaper) CollectQueueStats(ctx context.Context) ([]QueueStats, error) {
+	s.appliedMu.RLock()
+	names := make([]string, 0, len(s.appliedSignatures))
+	for name := range s.appliedSignatures {
+		names = append(names, name)
+	}
+	s.appliedMu.RUnlock()
+	sort.Strings(names)
+
+	var errs terr.MultiError
+	result := make([]QueueStats, 0, len(names))
+	for _, name := range names {
+		stats, err := s.QueueStats(ctx, name)
+		if err != nil {
+			errs.Add(err)
+			continue
+		}
+		result = append(result, stats)
+	}
+	return result, errs.ErrorOrNil()
+}
+
+func rootQdisc(qdiscs []QdiscStats) *QdiscStats {
+	for i := range qdiscs {
+		if qdiscs[i].Root {
+			return &qdiscs[i]
+		}
+	}
+	return nil
+}
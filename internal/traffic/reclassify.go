@@ -0,0 +1,76 @@
+package traffic
+
+import (
+	"context"
+	"log/slog"
+
+	terr "tcsss/internal/errors"
+)
+
+// recordClass remembers the classification an interface was last shaped under.
+func (s *Shaper) recordClass(name string, class ifaceClass) {
+	s.appliedMu.Lock()
+	s.appliedClasses[name] = class
+	s.appliedMu.Unlock()
+}
+
+// shapedLocked reports whether the interface was last classified into a profile the shaper
+// applies, as opposed to skipped or never seen. The caller holds appliedMu.
+func (s *Shaper) shapedLocked(name string) bool {
+	class, ok := s.appliedClasses[name]
+	return ok && class != classInternalVirtualSkip
+}
+
+// shaped is shapedLocked for callers that do not hold appliedMu.
+func (s *Shaper) shaped(name string) bool {
+	s.appliedMu.RLock()
+	defer s.appliedMu.RUnlock()
+	return s.shapedLocked(name)
+}
+
+// reclassifyInterfaces re-reads default routes and tears down every interface whose class changed
+// since it was last shaped. The affected names are returned so the caller can re-create their profiles.
+func (s *Shaper) reclassifyInterfaces(ctx context.Context) map[string]struct{} {
+	s.classifier.Invalidate()
+	links, err := s.listAndPrepareLinks(ctx)
+	if err != nil {
+		s.handleCategorizedError("reclassify interfaces failed", "", err, terr.CategoryRecoverable)
+		return nil
+	}
+
+	changed := map[string]struct{}{}
+	for _, link := range links {
+		attrs := link.Attrs()
+		if attrs == nil || attrs.Name == "" {
+			continue
+		}
+
+		s.appliedMu.RLock()
+		previous, known := s.appliedClasses[attrs.Name]
+		s.appliedMu.RUnlock()
+
+		current := s.classifier.Classify(attrs)
+		if !known || previous == current {
+			continue
+		}
+
+		if s.logger != nil {
+			s.logger.Info("interface classification changed",
+				slog.String("interface", attrs.Name),
+				slog.String("previous", previous.String()),
+				slog.String("current", current.String()))
+		}
+
+		s.teardownShaping(ctx, attrs.Name)
+
+		s.appliedMu.Lock()
+		delete(s.appliedSignatures, attrs.Name)
+		delete(s.appliedClasses, attrs.Name)
+		delete(s.bqlFailed, attrs.Name)
+		s.appliedMu.Unlock()
+
+		changed[attrs.Name] = struct{}{}
+	}
+
+	return changed
+}
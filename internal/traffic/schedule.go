@@ -0,0 +1,256 @@
+package traffic
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"tcsss/internal/config"
+)
+
+// ScheduleFile is the optional time-of-day shaping template read from the configuration directory.
+const ScheduleFile = "schedule.conf"
+
+// ScheduleEntry overrides CAKE bandwidth and diffserv settings on one interface during a weekly window.
+type ScheduleEntry struct {
+	Name      string
+	Interface string
+	// Days is indexed by time.Weekday; the window belongs to the day it starts on.
+	Days [7]bool
+	// Start and End are offsets from midnight; End <= Start wraps past midnight.
+	Start time.Duration
+	End   time.Duration
+	// Egress and Ingress are tc rates ("50mbit"), "unlimited", or a percentage of link speed ("50%").
+	Egress   string
+	Ingress  string
+	Diffserv string
+}
+
+// ScheduleSettings lists the configured schedule entries in file order.
+type ScheduleSettings struct {
+	Entries []ScheduleEntry
+}
+
+var (
+	weekdayNames = map[string]time.Weekday{
+		"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+		"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+	}
+
+	diffservPresets = map[string]struct{}{
+		"besteffort": {}, "diffserv3": {}, "diffserv4": {}, "diffserv8": {}, "precedence": {},
+	}
+)
+
+// LoadSchedule reads schedule.conf from the template directory. A missing file yields no entries.
+func LoadSchedule(templateDir string) (ScheduleSettings, error) {
+	data, err := os.ReadFile(filepath.Join(templateDir, ScheduleFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ScheduleSettings{}, nil
+		}
+		return ScheduleSettings{}, fmt.Errorf("read %s: %w", ScheduleFile, err)
+	}
+	return ParseSchedule(string(data))
+}
+
+// ParseSchedule parses entries of the form
+//
+//	<iface>.<name> = <days> <HH:MM>-<HH:MM> [egress=<rate>] [ingress=<rate>] [diffserv=<preset>]
+//
+// where days is "daily", a range ("mon-fri") or a list ("sat,sun").
+func ParseSchedule(content string) (ScheduleSettings, error) {
+	var settings ScheduleSettings
+	for lineNo, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return ScheduleSettings{}, fmt.Errorf("line %d: expected <iface>.<name> = <entry>", lineNo+1)
+		}
+		entry, err := parseScheduleEntry(strings.TrimSpace(parts[0]), config.StripInlineComment(parts[1]))
+		if err != nil {
+			return ScheduleSettings{}, fmt.Errorf("line %d: %w", lineNo+1, err)
+		}
+		settings.Entries = append(settings.Entries, entry)
+	}
+	return settings, nil
+}
+
+func parseScheduleEntry(key, value string) (ScheduleEntry, error) {
+	iface, name, ok := strings.Cut(key, ".")
+	if !ok || iface == "" || name == "" {
+		return ScheduleEntry{}, fmt.Errorf("invalid key %q, expected <iface>.<name>", key)
+	}
+	entry := ScheduleEntry{Name: name, Interface: iface}
+
+	fields := strings.Fields(value)
+	if len(fields) < 3 {
+		return ScheduleEntry{}, fmt.Errorf("entry %s: expected days, window and at least one override", key)
+	}
+
+	days, err := parseScheduleDays(fields[0])
+	if err != nil {
+		return ScheduleEntry{}, fmt.Errorf("entry %s: %w", key, err)
+	}
+	entry.Days = days
+
+	start, end, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return ScheduleEntry{}, fmt.Errorf("entry %s: invalid window %q", key, fields[1])
+	}
+	if entry.Start, err = parseClock(start); err != nil {
+		return ScheduleEntry{}, fmt.Errorf("entry %s: %w", key, err)
+	}
+	if entry.End, err = parseClock(end); err != nil {
+		return ScheduleEntry{}, fmt.Errorf("entry %s: %w", key, err)
+	}
+
+	for _, field := range fields[2:] {
+		k, v, ok := strings.Cut(field, "=")
+		if !ok || v == "" {
+			return ScheduleEntry{}, fmt.Errorf("entry %s: invalid override %q", key, field)
+		}
+		switch k {
+		case "egress":
+			entry.Egress, err = validateScheduleRate(v)
+		case "ingress":
+			entry.Ingress, err = validateScheduleRate(v)
+		case "diffserv":
+			if _, ok := diffservPresets[v]; !ok {
+				err = fmt.Errorf("unknown diffserv preset %q", v)
+			}
+			entry.Diffserv = v
+		default:
+			err = fmt.Errorf("unknown override %q", k)
+		}
+		if err != nil {
+			return ScheduleEntry{}, fmt.Errorf("entry %s: %w", key, err)
+		}
+	}
+	return entry, nil
+}
+
+func parseScheduleDays(spec string) ([7]bool, error) {
+	var days [7]bool
+	if spec == "daily" || spec == "*" {
+		for i := range days {
+			days[i] = true
+		}
+		return days, nil
+	}
+	for _, part := range strings.Split(strings.ToLower(spec), ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		start, ok := weekdayNames[from]
+		if !ok {
+			return days, fmt.Errorf("unknown day %q", from)
+		}
+		end := start
+		if isRange {
+			if end, ok = weekdayNames[to]; !ok {
+				return days, fmt.Errorf("unknown day %q", to)
+			}
+		}
+		for d := start; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == end {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+	hh, mm, ok := strings.Cut(value, ":")
+	h, errH := strconv.Atoi(hh)
+	m, errM := strconv.Atoi(mm)
+	if !ok || errH != nil || errM != nil || h < 0 || h > 24 || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+func validateScheduleRate(value string) (string, error) {
+	if pct, ok := strings.CutSuffix(value, "%"); ok {
+		n, err := strconv.ParseFloat(pct, 64)
+		if err != nil || n <= 0 || n > 100 {
+			return "", fmt.Errorf("invalid percentage %q", value)
+		}
+		return value, nil
+	}
+	if value == "unlimited" || (value[0] >= '0' && value[0] <= '9') {
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid rate %q", value)
+}
+
+// activeAt reports whether the entry's window covers t.
+func (e ScheduleEntry) activeAt(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+	if e.End > e.Start {
+		return e.Days[t.Weekday()] && offset >= e.Start && offset < e.End
+	}
+	// Overnight window: the evening part belongs to today, the morning part to yesterday.
+	if offset >= e.Start {
+		return e.Days[t.Weekday()]
+	}
+	return offset < e.End && e.Days[(t.Weekday()+6)%7]
+}
+
+// boundaries returns the start and end instants of the entry's window beginning on day.
+func (e ScheduleEntry) boundaries(day time.Time) []time.Time {
+	if !e.Days[day.Weekday()] {
+		return nil
+	}
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := e.End
+	if end <= e.Start {
+		end += 24 * time.Hour
+	}
+	return []time.Time{midnight.Add(e.Start), midnight.Add(end)}
+}
+
+// activeEntry returns the first entry for the interface whose window covers t.
+func (s ScheduleSettings) activeEntry(iface string, t time.Time) (ScheduleEntry, bool) {
+	for _, entry := range s.Entries {
+		if entry.Interface == iface && entry.activeAt(t) {
+			return entry, true
+		}
+	}
+	return ScheduleEntry{}, false
+}
+
+// nextTransition finds the next instant within a week at which the active entry for the interface changes.
+func (s ScheduleSettings) nextTransition(iface string, now time.Time) (time.Time, ScheduleEntry, bool) {
+	current, _ := s.activeEntry(iface, now)
+
+	var next time.Time
+	for _, entry := range s.Entries {
+		if entry.Interface != iface {
+			continue
+		}
+		for offset := -1; offset <= 7; offset++ {
+			for _, at := range entry.boundaries(now.AddDate(0, 0, offset)) {
+				if !at.After(now) || (!next.IsZero() && !at.Before(next)) {
+					continue
+				}
+				if candidate, _ := s.activeEntry(iface, at); candidate.Name != current.Name {
+					next = at
+				}
+			}
+		}
+	}
+	if next.IsZero() {
+		return time.Time{}, ScheduleEntry{}, false
+	}
+	upcoming, _ := s.activeEntry(iface, next)
+	return next, upcoming, true
+}
@@ -0,0 +1,236 @@
+package traffic
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	terr "tcsss/internal/errors"
+)
+
+// scheduleCheckInterval is how often the watcher looks for schedule transitions.
+const scheduleCheckInterval = 15 * time.Second
+
+// defaultScheduleName is reported while no schedule entry covers the interface.
+const defaultScheduleName = "default"
+
+func (s ScheduleSettings) hasInterface(iface string) bool {
+	for _, entry := range s.Entries {
+		if entry.Interface == iface {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduledProfile applies the active schedule entry of the interface to its CAKE qdiscs
+// and remembers which entry is now in effect.
+func (s *Shaper) scheduledProfile(iface string, profile shapingProfile) shapingProfile {
+	if !s.schedule.hasInterface(iface) {
+		return profile
+	}
+
+	entry, active := s.schedule.activeEntry(iface, time.Now())
+	name := defaultScheduleName
+	if active {
+		name = entry.Name
+		profile.rootQdisc = overrideCakeSpec(profile.rootQdisc, s.resolveScheduleRate(iface, entry.Egress), entry.Diffserv)
+		profile.ifbQdisc = overrideCakeSpec(profile.ifbQdisc, s.resolveScheduleRate(iface, entry.Ingress), entry.Diffserv)
+	}
+
+	s.scheduleMu.Lock()
+	s.activeSchedule[iface] = name
+	s.scheduleMu.Unlock()
+	return profile
+}
+
+// overrideCakeSpec returns a copy of a CAKE spec with the bandwidth and diffserv keywords replaced.
+func overrideCakeSpec(spec []string, rate, diffserv string) []string {
+	if len(spec) == 0 || spec[0] != "cake" || (rate == "" && diffserv == "") {
+		return spec
+	}
+
+	rateTokens := []string{"bandwidth", rate}
+	if rate == "unlimited" {
+		rateTokens = []string{"unlimited"}
+	}
+
+	result := make([]string, 0, len(spec)+1)
+	for i := 0; i < len(spec); i++ {
+		token := spec[i]
+		switch {
+		case rate != "" && token == "unlimited":
+			result = append(result, rateTokens...)
+		case rate != "" && token == "bandwidth" && i+1 < len(spec):
+			result = append(result, rateTokens...)
+			i++
+		case diffserv != "" && isDiffservPreset(token):
+			result = append(result, diffserv)
+		default:
+			result = append(result, token)
+		}
+	}
+	return result
+}
+
+func isDiffservPreset(token string) bool {
+	_, ok := diffservPresets[token]
+	return ok
+}
+
+// resolveScheduleRate converts a percentage into kbit of the interface's link speed.
+// An empty string is returned when the speed is unknown, leaving the bandwidth untouched.
+func (s *Shaper) resolveScheduleRate(iface, rate string) string {
+	pct, ok := strings.CutSuffix(rate, "%")
+	if !ok {
+		return rate
+	}
+	share, err := strconv.ParseFloat(pct, 64)
+	if err != nil {
+		return ""
+	}
+
+	speed := 0
+	if value, err := readSysfsValue(filepath.Join(sysfsNetRoot, iface, "speed")); err == nil {
+		speed, _ = strconv.Atoi(value)
+	}
+	if speed <= 0 {
+		if s.logger != nil {
+			s.logger.Debug("link speed unknown, ignoring percentage schedule rate",
+				slog.String("interface", iface),
+				slog.String("rate", rate))
+		}
+		return ""
+	}
+	return fmt.Sprintf("%.0fkbit", float64(speed)*1000*share/100)
+}
+
+// profileForClass maps an interface class to the profile configureClass would apply.
+func (s *Shaper) profileForClass(class ifaceClass) (shapingProfile, string, bool) {
+	switch class {
+	case classLoopback:
+		return s.profiles.loopback, "loopback", true
+	case classExternalPhysical:
+		return s.profiles.externalPhysical, "external-physical", true
+	case classExternalVirtual:
+		return s.profiles.externalVirtual, "external-virtual", true
+	case classInternalVirtual:
+		return s.profiles.internalVirtual, "internal-virtual", true
+	default:
+		return shapingProfile{}, "", false
+	}
+}
+
+// applySchedule moves interfaces onto their currently active schedule entry using tc qdisc change,
+// so existing flows keep their state. Interfaces whose change fails are returned for a full reapply.
+func (s *Shaper) applySchedule(ctx context.Context) map[string]struct{} {
+	if len(s.schedule.Entries) == 0 {
+		return nil
+	}
+
+	s.appliedMu.RLock()
+	classes := make(map[string]ifaceClass, len(s.appliedClasses))
+	for name, class := range s.appliedClasses {
+		if _, shaped := s.appliedSignatures[name]; shaped && s.schedule.hasInterface(name) {
+			classes[name] = class
+		}
+	}
+	s.appliedMu.RUnlock()
+
+	now := time.Now()
+	var failed map[string]struct{}
+	for name, class := range classes {
+		desired := defaultScheduleName
+		if entry, ok := s.schedule.activeEntry(name, now); ok {
+			desired = entry.Name
+		}
+		s.scheduleMu.Lock()
+		previous := s.activeSchedule[name]
+		s.scheduleMu.Unlock()
+		if previous == desired {
+			continue
+		}
+
+		if err := s.changeScheduledQdiscs(ctx, name, class); err != nil {
+			s.handleCategorizedError("schedule transition failed", name, err, terr.CategoryRecoverable)
+			s.appliedMu.Lock()
+			delete(s.appliedSignatures, name)
+			s.appliedMu.Unlock()
+			if failed == nil {
+				failed = map[string]struct{}{}
+			}
+			failed[name] = struct{}{}
+			continue
+		}
+
+		if s.logger != nil {
+			s.logger.Info("schedule transition applied",
+				slog.String("interface", name),
+				slog.String("previous", previous),
+				slog.String("current", desired))
+		}
+	}
+	return failed
+}
+
+func (s *Shaper) changeScheduledQdiscs(ctx context.Context, iface string, class ifaceClass) error {
+	base, profileName, ok := s.profileForClass(class)
+	if !ok {
+		return nil
+	}
+	link, err := s.netlink.LinkByName(iface)
+	if err != nil {
+		return wrapInterfaceError(fmt.Errorf("lookup link %s: %w", iface, err), iface, "schedule_lookup_link", terr.ErrorContext{Profile: profileName})
+	}
+	if link == nil || link.Attrs() == nil {
+		return nil
+	}
+
+	profile := s.scheduledProfile(iface, base)
+	if len(profile.rootQdisc) > 0 {
+		root := rootQdiscConfig(iface, profile.rootQdisc)
+		if err := s.run(ctx, "tc", root.ChangeArgs()...); err != nil {
+			return wrapInterfaceError(err, iface, "schedule_change_root_qdisc", terr.ErrorContext{Profile: profileName, Command: "tc qdisc change root"})
+		}
+	}
+	ifbName := truncateIfb(IfbPrefix + iface)
+	if len(profile.ifbQdisc) > 0 {
+		if _, err := s.netlink.LinkByName(ifbName); err == nil {
+			ifbRoot := ifbRootQdiscConfig(ifbName, profile.ifbQdisc)
+			if err := s.run(ctx, "tc", ifbRoot.ChangeArgs()...); err != nil {
+				return wrapInterfaceError(err, iface, "schedule_change_ifb_qdisc", terr.ErrorContext{Profile: profileName, IFB: ifbName, Command: "tc qdisc change ifb"})
+			}
+		}
+	}
+
+	mtuStr, queueLength := deriveProfileParameters(link.Attrs(), profile)
+	signature := s.makeSignature(iface, mtuStr, queueLength, profile)
+	s.appliedMu.Lock()
+	s.appliedSignatures[iface] = signature
+	s.appliedMu.Unlock()
+	return nil
+}
+
+// scheduleStatus reports the current and next schedule entry of an interface.
+func (s *Shaper) scheduleStatus(iface string, now time.Time) (string, string, time.Time) {
+	if !s.schedule.hasInterface(iface) {
+		return "", "", time.Time{}
+	}
+	current := defaultScheduleName
+	if entry, ok := s.schedule.activeEntry(iface, now); ok {
+		current = entry.Name
+	}
+	at, next, ok := s.schedule.nextTransition(iface, now)
+	if !ok {
+		return current, "", time.Time{}
+	}
+	nextName := next.Name
+	if nextName == "" {
+		nextName = defaultScheduleName
+	}
+	return current, nextName, at
+}
@@ -11,6 +11,25 @@ type WatcherSettings struct {
 	ReapplyInterval time.Duration
 	CleanupInterval time.Duration
 	ApplyTimeout    time.Duration
+	// BackoffBase and BackoffMax bound the exponential retry delay after failed applies.
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// FlapThreshold oper state changes within FlapWindow hold the interface down for HoldDown.
+	FlapWindow    time.Duration
+	FlapThreshold int
+	HoldDown      time.Duration
+	// DegradedAfter consecutive failures mark an interface degraded.
+	DegradedAfter int
+	// ResubscribeAttempts consecutive netlink subscription failures are tolerated before Watch gives up.
+	ResubscribeAttempts int
+	// StatusPath is the status file read by `tcsss status`.
+	StatusPath string
+}
+
+// BQLSettings bounds the byte queue limits applied to every TX queue of a shaped interface.
+type BQLSettings struct {
+	LimitMin int
+	LimitMax int
 }
 
 // ProfileSettings customises shaping profile parameters.
@@ -20,6 +39,16 @@ type ProfileSettings struct {
 	LoopbackMTUOverride int
 	InternalRTT         time.Duration
 	LoopbackRTT         time.Duration
+	PhysicalBQL         BQLSettings
+	VirtualBQL          BQLSettings
+	// NICProfile selects the ring/channel/coalescing preset for physical NICs: latency, balanced or throughput.
+	NICProfile string
+}
+
+// SteeringSettings controls RPS/RFS/XPS and IRQ affinity tuning on physical NICs.
+type SteeringSettings struct {
+	Disabled    bool
+	IRQAffinity bool
 }
 
 // Settings encapsulates the inputs required to build a Shaper.
@@ -27,17 +56,28 @@ type Settings struct {
 	Routes   route.WindowConfig
 	Watcher  WatcherSettings
 	Profiles ProfileSettings
+	Steering SteeringSettings
+	Schedule ScheduleSettings
 }
 
 const (
 	defaultApplyTimeout    = 45 * time.Second
 	defaultReapplyInterval = 2 * time.Second
 	defaultCleanupInterval = 5 * time.Minute
+	defaultBackoffBase     = 2 * time.Second
+	defaultBackoffMax      = 5 * time.Minute
+	defaultFlapWindow      = time.Minute
+	defaultFlapThreshold   = 6
+	defaultHoldDown        = 2 * time.Minute
+	defaultDegradedAfter   = 5
+	defaultResubscribes    = 5
 	defaultQueueLen        = 10001
 	defaultLoopbackQueue   = 10000
 	defaultLoopbackMTU     = 65520
 	defaultInternalRTT     = 100 * time.Microsecond
 	defaultLoopbackRTT     = 20 * time.Microsecond
+	defaultPhysicalBQLMax  = 256 * 1024
+	defaultVirtualBQLMax   = 64 * 1024
 )
 
 func (s Settings) withDefaults() Settings {
@@ -51,6 +91,30 @@ func (s Settings) withDefaults() Settings {
 	if s.Watcher.ApplyTimeout <= 0 {
 		s.Watcher.ApplyTimeout = defaultApplyTimeout
 	}
+	if s.Watcher.BackoffBase <= 0 {
+		s.Watcher.BackoffBase = defaultBackoffBase
+	}
+	if s.Watcher.BackoffMax < s.Watcher.BackoffBase {
+		s.Watcher.BackoffMax = max(defaultBackoffMax, s.Watcher.BackoffBase)
+	}
+	if s.Watcher.FlapWindow <= 0 {
+		s.Watcher.FlapWindow = defaultFlapWindow
+	}
+	if s.Watcher.FlapThreshold <= 0 {
+		s.Watcher.FlapThreshold = defaultFlapThreshold
+	}
+	if s.Watcher.HoldDown <= 0 {
+		s.Watcher.HoldDown = defaultHoldDown
+	}
+	if s.Watcher.DegradedAfter <= 0 {
+		s.Watcher.DegradedAfter = defaultDegradedAfter
+	}
+	if s.Watcher.ResubscribeAttempts <= 0 {
+		s.Watcher.ResubscribeAttempts = defaultResubscribes
+	}
+	if s.Watcher.StatusPath == "" {
+		s.Watcher.StatusPath = DefaultStatusPath
+	}
 
 	if s.Profiles.DefaultQueueLen <= 0 {
 		s.Profiles.DefaultQueueLen = defaultQueueLen
@@ -67,5 +131,11 @@ func (s Settings) withDefaults() Settings {
 	if s.Profiles.LoopbackRTT <= 0 {
 		s.Profiles.LoopbackRTT = defaultLoopbackRTT
 	}
+	if s.Profiles.PhysicalBQL.LimitMax <= 0 {
+		s.Profiles.PhysicalBQL.LimitMax = defaultPhysicalBQLMax
+	}
+	if s.Profiles.VirtualBQL.LimitMax <= 0 {
+		s.Profiles.VirtualBQL.LimitMax = defaultVirtualBQLMax
+	}
 	return s
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	terr "tcsss/internal/errors"
@@ -18,6 +19,16 @@ type Shaper struct {
 	classifier        *InterfaceClassifier
 	appliedMu         sync.RWMutex
 	appliedSignatures map[string]string
+	appliedClasses    map[string]ifaceClass
+	// bqlFailed marks interfaces whose driver refused or clamped the byte queue limits, so
+	// isAlreadyConfigured does not reapply the whole profile on every event for them.
+	bqlFailed         map[string]bool
+	health            *healthTracker
+	statusPath        string
+	lastStatus        []byte
+	resubscriptions   atomic.Int64
+	backoffBase       time.Duration
+	maxResubscribes   int
 	didInitialCleanup bool
 	netlink           NetlinkClient
 	executor          CommandExecutor
@@ -25,6 +36,12 @@ type Shaper struct {
 	cleanupInterval   time.Duration
 	applyTimeout      time.Duration
 	profiles          profileSet
+	steering          SteeringSettings
+	topologyMu        sync.Mutex
+	cpuTopology       string
+	schedule          ScheduleSettings
+	scheduleMu        sync.Mutex
+	activeSchedule    map[string]string
 }
 
 // NewShaper constructs a traffic Shaper.
@@ -44,12 +61,21 @@ func NewShaperWithDependencies(logger *slog.Logger, settings Settings, netlinkCl
 		}),
 		classifier:        NewInterfaceClassifier(logger, netlinkClient),
 		appliedSignatures: make(map[string]string),
+		appliedClasses:    make(map[string]ifaceClass),
+		bqlFailed:         make(map[string]bool),
+		health:            newHealthTracker(logger, settings.Watcher),
+		statusPath:        settings.Watcher.StatusPath,
+		backoffBase:       settings.Watcher.BackoffBase,
+		maxResubscribes:   settings.Watcher.ResubscribeAttempts,
 		netlink:           netlinkClient,
 		executor:          executor,
 		reapplyInterval:   settings.Watcher.ReapplyInterval,
 		cleanupInterval:   settings.Watcher.CleanupInterval,
 		applyTimeout:      settings.Watcher.ApplyTimeout,
 		profiles:          newProfileSet(settings.Profiles),
+		steering:          settings.Steering,
+		schedule:          settings.Schedule,
+		activeSchedule:    make(map[string]string),
 	}
 }
 
@@ -65,5 +91,7 @@ func (s *Shaper) Apply(ctx context.Context) error {
 		// Continue with traffic shaping even if route optimization fails
 	}
 
-	return s.applyInterfaces(ctx, nil)
+	err := s.applyInterfaces(ctx, nil)
+	s.publishStatus()
+	return err
 }
@@ -112,6 +112,9 @@ func (s *Shaper) configureProfile(ctx context.Context, attrs *netlink.LinkAttrs,
 		s.configureRootQdiscStep,
 		s.configureIngressAndIfbStep,
 		s.ensureOffloadsStep,
+		s.configureNICTuningStep,
+		s.configureBQLStep,
+		s.configureSteeringStep,
 	}
 
 	if err := s.runProfileSteps(ctx, profileCtx, steps); err != nil {
@@ -132,10 +135,11 @@ func (s *Shaper) buildProfileContext(attrs *netlink.LinkAttrs, profile shapingPr
 	}
 
 	iface := attrs.Name
+	profile = s.scheduledProfile(iface, profile)
 	mtuStr, queueLength := deriveProfileParameters(attrs, profile)
-	signature := s.makeSignature(mtuStr, queueLength, profile)
+	signature := s.makeSignature(iface, mtuStr, queueLength, profile)
 
-	if s.isAlreadyConfigured(iface, signature) {
+	if s.isAlreadyConfigured(iface, signature, profile) {
 		return nil, true, nil
 	}
 
@@ -257,27 +261,41 @@ func (s *Shaper) processLink(ctx context.Context, link netlink.Link, only map[st
 	if !shouldProcess {
 		return false, nil
 	}
+	if !s.health.allow(name) {
+		if s.logger != nil {
+			s.logger.Debug("interface apply deferred", slog.String("interface", name))
+		}
+		return false, nil
+	}
+
+	err := s.configureClass(ctx, name, attrs)
+	s.health.record(name, err)
+	return true, err
+}
 
+// configureClass classifies the interface and applies the matching profile.
+func (s *Shaper) configureClass(ctx context.Context, name string, attrs *netlink.LinkAttrs) error {
 	class := s.classifier.Classify(attrs)
+	s.recordClass(name, class)
 	switch class {
 	case classLoopback:
-		return true, s.applyProfile(ctx, name, attrs, s.profiles.loopback, "loopback", "loopback configure failed")
+		return s.applyProfile(ctx, name, attrs, s.profiles.loopback, "loopback", "loopback configure failed")
 	case classExternalPhysical:
-		return true, s.applyProfile(ctx, name, attrs, s.profiles.externalPhysical, "external-physical", "external physical configure failed")
+		return s.applyProfile(ctx, name, attrs, s.profiles.externalPhysical, "external-physical", "external physical configure failed")
 	case classExternalVirtual:
-		return true, s.applyProfile(ctx, name, attrs, s.profiles.externalVirtual, "external-virtual", "external virtual configure failed")
+		return s.applyProfile(ctx, name, attrs, s.profiles.externalVirtual, "external-virtual", "external virtual configure failed")
 	case classInternalVirtual:
-		return true, s.applyProfile(ctx, name, attrs, s.profiles.internalVirtual, "internal-virtual", "internal virtual configure failed")
+		return s.applyProfile(ctx, name, attrs, s.profiles.internalVirtual, "internal-virtual", "internal virtual configure failed")
 	case classInternalVirtualSkip:
 		if s.logger != nil {
 			s.logger.Debug("skipping internal virtual interface", slog.String("interface", name))
 		}
-		return true, nil
+		return nil
 	default:
 		if s.logger != nil {
 			s.logger.Warn("unknown interface classification", slog.String("interface", name))
 		}
-		return true, nil
+		return nil
 	}
 }
 
@@ -376,7 +394,7 @@ func deriveProfileParameters(attrs *netlink.LinkAttrs, profile shapingProfile) (
 	return mtuStr, queueLength
 }
 
-func (s *Shaper) isAlreadyConfigured(iface, sig string) bool {
+func (s *Shaper) isAlreadyConfigured(iface, sig string, profile shapingProfile) bool {
 	s.appliedMu.RLock()
 	defer s.appliedMu.RUnlock()
 
@@ -392,5 +410,11 @@ func (s *Shaper) isAlreadyConfigured(iface, sig string) bool {
 	}
 
 	attrs := link.Attrs()
-	return attrs != nil && (attrs.Flags&net.FlagUp) != 0
+	if attrs == nil || (attrs.Flags&net.FlagUp) == 0 {
+		return false
+	}
+
+	// Driver resets and queue count changes restore kernel BQL defaults without touching tc state.
+	// Limits the driver would not take are not rechecked, or every event would reapply.
+	return s.bqlFailed[iface] || bqlInSync(iface, profile.bql)
 }
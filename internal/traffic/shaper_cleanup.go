@@ -67,7 +67,18 @@ func (s *Shaper) cleanupStaleSignatures() error {
 			delete(s.appliedSignatures, name)
 		}
 	}
+	for name := range s.appliedClasses {
+		if _, exists := current[name]; !exists {
+			delete(s.appliedClasses, name)
+		}
+	}
+	for name := range s.bqlFailed {
+		if _, exists := current[name]; !exists {
+			delete(s.bqlFailed, name)
+		}
+	}
 	s.appliedMu.Unlock()
+	s.health.forget(current)
 
 	return nil
 }
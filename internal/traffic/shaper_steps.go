@@ -109,3 +109,43 @@ func (s *Shaper) ensureOffloadsStep(ctx context.Context, pc *profileContext) err
 	s.ensureOffloads(ctx, pc.iface, pc.profile.offloads)
 	return nil
 }
+
+func (s *Shaper) configureNICTuningStep(ctx context.Context, pc *profileContext) error {
+	s.ensureNICTuning(ctx, pc.iface, pc.profile.nic)
+	return nil
+}
+
+func (s *Shaper) configureBQLStep(ctx context.Context, pc *profileContext) error {
+	err := s.ensureBQL(pc.iface, pc.profile.bql)
+	s.appliedMu.Lock()
+	if err != nil {
+		s.bqlFailed[pc.iface] = true
+	} else {
+		delete(s.bqlFailed, pc.iface)
+	}
+	s.appliedMu.Unlock()
+	if err != nil {
+		s.logOptional("byte queue limits apply skipped", pc.iface, err, terr.ErrorContext{
+			Operation: "configure_bql",
+			Profile:   pc.profileName,
+			Extra: map[string]any{
+				"limit_min": pc.profile.bql.limitMin,
+				"limit_max": pc.profile.bql.limitMax,
+			},
+		})
+	}
+	return nil
+}
+
+func (s *Shaper) configureSteeringStep(ctx context.Context, pc *profileContext) error {
+	if !pc.profile.steering || s.steering.Disabled {
+		return nil
+	}
+	if err := s.ensureSteering(pc.iface); err != nil {
+		s.logOptional("cpu steering apply skipped", pc.iface, err, terr.ErrorContext{
+			Operation: "configure_steering",
+			Profile:   pc.profileName,
+		})
+	}
+	return nil
+}
@@ -7,7 +7,7 @@ import (
 )
 
 // makeSignature creates a lightweight signature describing desired state to avoid redundant tc/ethtool calls
-func (s *Shaper) makeSignature(mtu, qlen string, profile shapingProfile) string {
+func (s *Shaper) makeSignature(iface, mtu, qlen string, profile shapingProfile) string {
 	var b strings.Builder
 	b.WriteString("mtu=")
 	b.WriteString(mtu)
@@ -28,5 +28,19 @@ func (s *Shaper) makeSignature(mtu, qlen string, profile shapingProfile) string
 		sort.Strings(pairs)
 		b.WriteString(strings.Join(pairs, ","))
 	}
+	if profile.bql.enabled() {
+		b.WriteString(";bql=")
+		b.WriteString(profile.bql.limitMin)
+		b.WriteString("/")
+		b.WriteString(profile.bql.limitMax)
+	}
+	if profile.nic.enabled() {
+		b.WriteString(";nic=")
+		b.WriteString(profile.nic.signature(len(s.onlineCPUs())))
+	}
+	if profile.steering && !s.steering.Disabled {
+		b.WriteString(";steer=")
+		b.WriteString(s.steeringSignature(iface))
+	}
 	return b.String()
 }
@@ -0,0 +1,147 @@
+package traffic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	route "tcsss/internal/route"
+)
+
+// DefaultStatusPath is where the watcher publishes its status for `tcsss status`.
+const DefaultStatusPath = "/run/tcsss/status.json"
+
+// Status is the watcher state published to the status file.
+type Status struct {
+	UpdatedAt       time.Time         `json:"updated_at"`
+	Resubscriptions int64             `json:"resubscriptions"`
+	Interfaces      []InterfaceStatus `json:"interfaces"`
+	// Uplinks reports the route tuning of every uplink from the last route optimization pass.
+	Uplinks []route.UplinkReport `json:"uplinks,omitempty"`
+}
+
+// InterfaceStatus reports the classification and apply health of a single interface.
+type InterfaceStatus struct {
+	Name        string    `json:"name"`
+	Class       string    `json:"class,omitempty"`
+	State       string    `json:"state"`
+	Failures    int       `json:"failures,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+	NextAttempt time.Time `json:"next_attempt,omitzero"`
+	HoldUntil   time.Time `json:"hold_until,omitzero"`
+	// Schedule is the active schedule entry, or "default" outside every window.
+	Schedule       string    `json:"schedule,omitempty"`
+	NextSchedule   string    `json:"next_schedule,omitempty"`
+	NextScheduleAt time.Time `json:"next_schedule_at,omitzero"`
+}
+
+// Status returns the current per-interface health merged with the last applied classification.
+// Only interfaces the shaper configures are listed.
+func (s *Shaper) Status() Status {
+	health := map[string]InterfaceStatus{}
+	for _, item := range s.health.snapshot() {
+		health[item.Name] = item
+	}
+
+	byName := map[string]InterfaceStatus{}
+	s.appliedMu.RLock()
+	for name, class := range s.appliedClasses {
+		if !s.shapedLocked(name) {
+			continue
+		}
+		item, ok := health[name]
+		if !ok {
+			item = InterfaceStatus{Name: name, State: HealthHealthy}
+		}
+		item.Class = class.String()
+		byName[name] = item
+	}
+	s.appliedMu.RUnlock()
+
+	now := time.Now()
+	for name, item := range byName {
+		item.Schedule, item.NextSchedule, item.NextScheduleAt = s.scheduleStatus(name, now)
+		byName[name] = item
+	}
+
+	status := Status{
+		UpdatedAt:       now.UTC(),
+		Resubscriptions: s.resubscriptions.Load(),
+		Interfaces:      make([]InterfaceStatus, 0, len(byName)),
+		Uplinks:         s.routeOptimizer.Uplinks(),
+	}
+	for _, item := range byName {
+		status.Interfaces = append(status.Interfaces, item)
+	}
+	sort.Slice(status.Interfaces, func(i, j int) bool {
+		return status.Interfaces[i].Name < status.Interfaces[j].Name
+	})
+	return status
+}
+
+// publishStatus rewrites the status file when anything other than the timestamp changed.
+func (s *Shaper) publishStatus() {
+	if s.statusPath == "" {
+		return
+	}
+
+	status := s.Status()
+	compare := status
+	compare.UpdatedAt = time.Time{}
+	body, err := json.Marshal(compare)
+	if err != nil {
+		return
+	}
+	if bytes.Equal(body, s.lastStatus) {
+		return
+	}
+
+	if err := WriteStatus(s.statusPath, status); err != nil {
+		if s.logger != nil {
+			s.logger.Debug("status file not written",
+				slog.String("path", s.statusPath),
+				slog.String("error", err.Error()))
+		}
+		return
+	}
+	s.lastStatus = body
+}
+
+// WriteStatus atomically replaces the status file.
+func WriteStatus(path string, status Status) error {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode status: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create %s: %w", filepath.Dir(path), err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("rename %s: %w", tmp, err)
+	}
+	return nil
+}
+
+// ReadStatus loads a status file written by a running daemon.
+func ReadStatus(path string) (Status, error) {
+	var status Status
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return status, fmt.Errorf("read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return status, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return status, nil
+}
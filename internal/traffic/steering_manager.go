@@ -0,0 +1,356 @@
+package traffic
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"tcsss/internal/detector"
+	terr "tcsss/internal/errors"
+	"tcsss/internal/sysinfo"
+)
+
+const (
+	procInterruptsPath     = "/proc/interrupts"
+	procIRQRoot            = "/proc/irq"
+	rpsSockFlowEntriesPath = "/proc/sys/net/core/rps_sock_flow_entries"
+)
+
+// sockFlowEntriesByTier sizes the global RFS table; each entry costs a few bytes per CPU.
+var sockFlowEntriesByTier = map[detector.MemoryTier]int{
+	detector.MemoryTier1GB:  4096,
+	detector.MemoryTier4GB:  16384,
+	detector.MemoryTier8GB:  32768,
+	detector.MemoryTier12GB: 65536,
+}
+
+// steeringPolicy captures the CPU steering layout chosen for a physical NIC.
+type steeringPolicy struct {
+	cpus            []int
+	rps             bool
+	sockFlowEntries int
+	flowCntPerQueue int
+	xps             bool
+	irqAffinity     bool
+}
+
+// selectSteeringPolicy picks RPS/RFS/XPS behaviour from the CPU count, memory tier and queue layout.
+//   - Single CPU hosts get no steering at all.
+//   - RPS/RFS is enabled only when the NIC has fewer RX queues than online CPUs.
+//   - XPS maps CPUs onto TX queues round-robin whenever more than one TX queue exists.
+func selectSteeringPolicy(cpus []int, tier detector.MemoryTier, rxQueues, txQueues int, irqAffinity bool) steeringPolicy {
+	policy := steeringPolicy{cpus: cpus}
+	if len(cpus) <= 1 {
+		return policy
+	}
+
+	if rxQueues > 0 && rxQueues < len(cpus) {
+		entries, ok := sockFlowEntriesByTier[tier]
+		if !ok {
+			entries = sockFlowEntriesByTier[detector.MemoryTier1GB]
+		}
+		if len(cpus) > 8 {
+			entries *= 2
+		}
+		policy.rps = true
+		policy.sockFlowEntries = entries
+		policy.flowCntPerQueue = entries / rxQueues
+	}
+
+	policy.xps = txQueues > 1
+	policy.irqAffinity = irqAffinity
+	return policy
+}
+
+func (p steeringPolicy) active() bool {
+	return len(p.cpus) > 1
+}
+
+// onlineCPUs returns the online CPU IDs, falling back to the scheduler view when sysfs is unavailable.
+func (s *Shaper) onlineCPUs() []int {
+	cpus, err := sysinfo.ReadOnlineCPUs(sysinfo.OnlineCPUsPath)
+	if err == nil {
+		return cpus
+	}
+	if s.logger != nil {
+		s.logger.Debug("online cpu detection failed, using runtime cpu count", slog.String("error", err.Error()))
+	}
+	fallback := make([]int, runtime.NumCPU())
+	for i := range fallback {
+		fallback[i] = i
+	}
+	return fallback
+}
+
+// cpuTopologyChanged reports whether the online CPU set differs from the previous observation.
+func (s *Shaper) cpuTopologyChanged() bool {
+	current := sysinfo.FormatCPUList(s.onlineCPUs())
+
+	s.topologyMu.Lock()
+	defer s.topologyMu.Unlock()
+
+	previous := s.cpuTopology
+	s.cpuTopology = current
+	if previous == "" || previous == current {
+		return false
+	}
+	if s.logger != nil {
+		s.logger.Info("cpu topology changed",
+			slog.String("previous", previous),
+			slog.String("current", current))
+	}
+	return true
+}
+
+// steeringSignature describes the inputs of the steering policy so topology changes force a reapply.
+func (s *Shaper) steeringSignature(iface string) string {
+	rxQueues := len(queueDirs(iface, "rx"))
+	txQueues := len(queueDirs(iface, "tx"))
+	return fmt.Sprintf("%s/rx%d/tx%d/irq=%t",
+		sysinfo.FormatCPUList(s.onlineCPUs()), rxQueues, txQueues, s.steering.IRQAffinity)
+}
+
+// ensureSteering writes rps_cpus, rps_flow_cnt and xps_cpus per queue and optionally spreads NIC IRQs.
+func (s *Shaper) ensureSteering(iface string) error {
+	rxDirs := queueDirs(iface, "rx")
+	txDirs := queueDirs(iface, "tx")
+	tier, _ := detector.DetectMemoryTier()
+
+	policy := selectSteeringPolicy(s.onlineCPUs(), tier, len(rxDirs), len(txDirs), s.steering.IRQAffinity)
+	if !policy.active() {
+		if s.logger != nil {
+			s.logger.Debug("cpu steering skipped on single cpu host", slog.String("interface", iface))
+		}
+		return nil
+	}
+
+	var errs terr.MultiError
+	changed := 0
+
+	if policy.rps {
+		wrote, err := writeSysfsIfChanged(rpsSockFlowEntriesPath, strconv.Itoa(policy.sockFlowEntries), equalNumeric)
+		errs.Add(err)
+		changed += wrote
+	}
+
+	rpsMask := "0"
+	flowCnt := "0"
+	if policy.rps {
+		rpsMask = cpuMask(policy.cpus)
+		flowCnt = strconv.Itoa(policy.flowCntPerQueue)
+	}
+	for _, dir := range rxDirs {
+		wrote, err := writeSysfsIfChanged(filepath.Join(dir, "rps_cpus"), rpsMask, equalCPUMask)
+		errs.Add(err)
+		changed += wrote
+		wrote, err = writeSysfsIfChanged(filepath.Join(dir, "rps_flow_cnt"), flowCnt, equalNumeric)
+		errs.Add(err)
+		changed += wrote
+	}
+
+	if policy.xps {
+		for i, dir := range txDirs {
+			mask := cpuMask(xpsCPUsForQueue(policy.cpus, i, len(txDirs)))
+			wrote, err := writeSysfsIfChanged(filepath.Join(dir, "xps_cpus"), mask, equalCPUMask)
+			errs.Add(err)
+			changed += wrote
+		}
+	}
+
+	irqs := 0
+	if policy.irqAffinity {
+		for i, irq := range interfaceIRQs(iface) {
+			cpu := policy.cpus[i%len(policy.cpus)]
+			path := filepath.Join(procIRQRoot, strconv.Itoa(irq), "smp_affinity_list")
+			wrote, err := writeSysfsIfChanged(path, strconv.Itoa(cpu), equalCPUList)
+			errs.Add(err)
+			changed += wrote
+			irqs++
+		}
+	}
+
+	if changed > 0 && s.logger != nil {
+		s.logger.Info("cpu steering updated",
+			slog.String("interface", iface),
+			slog.String("cpus", sysinfo.FormatCPUList(policy.cpus)),
+			slog.Int("rx_queues", len(rxDirs)),
+			slog.Int("tx_queues", len(txDirs)),
+			slog.Bool("rps", policy.rps),
+			slog.Int("rps_sock_flow_entries", policy.sockFlowEntries),
+			slog.Bool("xps", policy.xps),
+			slog.Int("irqs", irqs),
+			slog.Int("changed", changed))
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// queueDirs lists the sysfs directories of the interface's rx-* or tx-* queues in queue order.
+func queueDirs(iface, kind string) []string {
+	dirs, err := filepath.Glob(filepath.Join(sysfsNetRoot, iface, "queues", kind+"-*"))
+	if err != nil {
+		return nil
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return queueIndex(dirs[i]) < queueIndex(dirs[j])
+	})
+	return dirs
+}
+
+func queueIndex(dir string) int {
+	base := filepath.Base(dir)
+	idx := strings.LastIndex(base, "-")
+	if idx < 0 {
+		return 0
+	}
+	n, err := strconv.Atoi(base[idx+1:])
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// xpsCPUsForQueue assigns CPU i to TX queue i%queues.
+func xpsCPUsForQueue(cpus []int, queue, queues int) []int {
+	var result []int
+	for i, cpu := range cpus {
+		if i%queues == queue {
+			result = append(result, cpu)
+		}
+	}
+	return result
+}
+
+// queueIRQMarkers identify per-queue vectors in /proc/interrupts names such as eth0-TxRx-3,
+// eth0-rx-0 or ice-eth0-TxRx-1. Other vectors of the device (admin queue, mailbox, link state)
+// are left where they are.
+var queueIRQMarkers = []string{"-txrx-", "-rx-", "-tx-"}
+
+// interfaceIRQs finds the queue IRQ lines of the interface by their /proc/interrupts names.
+// Devices whose vectors are not named after the interface are left alone: their MSI vectors
+// cannot be told apart from admin and mailbox ones.
+func interfaceIRQs(iface string) []int {
+	data, err := os.ReadFile(procInterruptsPath)
+	if err != nil {
+		return nil
+	}
+	var irqs []int
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		irq, err := strconv.Atoi(strings.TrimSuffix(fields[0], ":"))
+		if err != nil {
+			continue
+		}
+		for _, name := range fields[1:] {
+			if isQueueIRQ(strings.TrimSuffix(name, ","), iface) {
+				irqs = append(irqs, irq)
+				break
+			}
+		}
+	}
+	sort.Ints(irqs)
+	return irqs
+}
+
+func isQueueIRQ(name, iface string) bool {
+	rest, ok := strings.CutPrefix(name, iface+"-")
+	if !ok {
+		// Some drivers prefix their own name, as in ice-eth0-TxRx-0.
+		_, rest, ok = strings.Cut(name, "-"+iface+"-")
+	}
+	if !ok {
+		return false
+	}
+	rest = "-" + strings.ToLower(rest)
+	for _, marker := range queueIRQMarkers {
+		if strings.HasPrefix(rest, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeSysfsIfChanged writes value unless equal reports the current content already matches.
+// It returns 1 when a write happened so callers can count changes.
+func writeSysfsIfChanged(path, value string, equal func(current, desired string) bool) (int, error) {
+	if current, err := readSysfsValue(path); err == nil && equal(current, value) {
+		return 0, nil
+	}
+	if err := os.WriteFile(path, []byte(value), 0o644); err != nil {
+		return 0, fmt.Errorf("write %s: %w", path, err)
+	}
+	return 1, nil
+}
+
+func equalNumeric(current, desired string) bool {
+	a, errA := strconv.ParseInt(strings.TrimSpace(current), 10, 64)
+	b, errB := strconv.ParseInt(strings.TrimSpace(desired), 10, 64)
+	if errA != nil || errB != nil {
+		return strings.TrimSpace(current) == strings.TrimSpace(desired)
+	}
+	return a == b
+}
+
+func equalCPUMask(current, desired string) bool {
+	a, okA := parseCPUMask(current)
+	b, okB := parseCPUMask(desired)
+	return okA && okB && sysinfo.FormatCPUList(a) == sysinfo.FormatCPUList(b)
+}
+
+func equalCPUList(current, desired string) bool {
+	a, errA := sysinfo.ParseCPUList(current)
+	b, errB := sysinfo.ParseCPUList(desired)
+	return errA == nil && errB == nil && sysinfo.FormatCPUList(a) == sysinfo.FormatCPUList(b)
+}
+
+// cpuMask renders CPU IDs as the comma separated 32-bit hex words used by rps_cpus and xps_cpus.
+func cpuMask(cpus []int) string {
+	if len(cpus) == 0 {
+		return "0"
+	}
+	words := make([]uint32, cpus[len(cpus)-1]/32+1)
+	for _, cpu := range cpus {
+		words[cpu/32] |= 1 << uint(cpu%32)
+	}
+
+	parts := make([]string, 0, len(words))
+	for i := len(words) - 1; i >= 0; i-- {
+		if i == len(words)-1 {
+			parts = append(parts, strconv.FormatUint(uint64(words[i]), 16))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%08x", words[i]))
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseCPUMask converts a comma separated hex mask back into sorted CPU IDs.
+func parseCPUMask(mask string) ([]int, bool) {
+	words := strings.Split(strings.TrimSpace(mask), ",")
+	var cpus []int
+	for i := len(words) - 1; i >= 0; i-- {
+		word := strings.TrimSpace(words[i])
+		if word == "" {
+			continue
+		}
+		value, err := strconv.ParseUint(word, 16, 32)
+		if err != nil {
+			return nil, false
+		}
+		base := (len(words) - 1 - i) * 32
+		for bit := 0; bit < 32; bit++ {
+			if value&(1<<uint(bit)) != 0 {
+				cpus = append(cpus, base+bit)
+			}
+		}
+	}
+	return cpus, true
+}
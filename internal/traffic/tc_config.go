@@ -12,7 +12,16 @@ type QdiscConfig struct {
 
 // ReplaceArgs renders the tc arguments required to replace the qdisc.
 func (qc QdiscConfig) ReplaceArgs() []string {
-	args := []string{"qdisc", "replace", "dev", qc.Device}
+	return qc.args("replace")
+}
+
+// ChangeArgs renders the tc arguments that modify the qdisc in place without resetting its flows.
+func (qc QdiscConfig) ChangeArgs() []string {
+	return qc.args("change")
+}
+
+func (qc QdiscConfig) args(verb string) []string {
+	args := []string{"qdisc", verb, "dev", qc.Device}
 
 	switch {
 	case qc.Root: